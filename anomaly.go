@@ -0,0 +1,75 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Week over week comparison for spotting broken scrapes. A town doesn't
+    lose half of its restaurants in a week, the parser does.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Compares the week with the previous one and returns a description of
+// every anomaly found. The previous week may be nil, in which case only
+// the week itself is checked.
+//
+func DetectAnomalies(current *DataStruct, previous *DataStruct, maxDrop float64) []string {
+	var anomalies = make([]string, 0);
+	var count, previousCount, empty = 0, 0, 0;
+
+	for day := 0; day < 5; day++ {
+		var list = current.Days[day].Restaurants;
+		for i := 0; i < len(list); i++ {
+			if strings.TrimSpace(list[i].Menu) == "" {
+				empty++;
+			}
+		}
+
+		// Holidays in either week would skew the comparison
+		//
+		if previous == nil || current.Days[day].Holiday != "" || previous.Days[day].Holiday != "" {
+			continue;
+		}
+		count += len(list) + len(current.Days[day].Specials);
+		previousCount += len(previous.Days[day].Restaurants) + len(previous.Days[day].Specials);
+	}
+
+	if previousCount > 0 {
+		var drop = 1 - float64(count) / float64(previousCount);
+		if drop > maxDrop {
+			anomalies = append(anomalies, fmt.Sprintf("%s v%d: %d restaurants compared to %d the week before", current.City, current.Week, count, previousCount));
+		}
+	}
+
+	var total = 0;
+	for day := 0; day < 5; day++ {
+		total += len(current.Days[day].Restaurants);
+	}
+	if total > 0 && empty * 2 > total {
+		anomalies = append(anomalies, fmt.Sprintf("%s v%d: %d of %d menus are empty", current.City, current.Week, empty, total));
+	}
+	return anomalies;
+}
+
+// Logs the alert and posts it to the alert URL, if there is one
+//
+func Alert(alertUrl string, text string) {
+	log.Println("ALERT:", text);
+
+	if alertUrl == "" {
+		return;
+	}
+	res, err := Do(&Request{ Method: "POST", Url: alertUrl, Body: text, ContentType: "text/plain; charset=utf-8" });
+	if err != nil {
+		log.Println(err);
+		return;
+	}
+	res.Body.Close();
+}
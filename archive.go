@@ -0,0 +1,247 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Archiving of the raw HTML documents downloaded from Lunchguiden, so
+    that any published JSON file can be reproduced from its inputs, and
+    of the published JSON files themselves for comparisons over weeks.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"io/ioutil"
+	"json"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stores the raw HTML document in the archive directory, named by its md5
+// hash, and returns the hash so it can be referenced from the JSON data.
+// Documents that already exist in the archive are not written again.
+// NOTE: The documents are stored as parsed, after conversion to UTF-8.
+//
+func ArchiveSnapshot(dir string, inData []byte) (string, os.Error) {
+	var hashStr, _ = GenerateHash(inData);
+	var path = SnapshotPath(dir, hashStr);
+
+	// Identical documents are only stored once
+	//
+	if _, err := os.Stat(path); err == nil {
+		return hashStr, nil;
+	}
+
+	if err := os.MkdirAll(fmt.Sprintf("%s/html", dir), 0755); err != nil {
+		return "", err;
+	}
+	if err := ioutil.WriteFile(path, inData, 0644); err != nil {
+		return "", err;
+	}
+	return hashStr, nil;
+}
+
+// Returns the path of an archived HTML document with the given hash
+//
+func SnapshotPath(dir string, hashStr string) string {
+	return fmt.Sprintf("%s/html/%s.html", dir, hashStr);
+}
+
+// Returns the directory where the published JSON files of a city are kept
+//
+func OutputDir(dir string, city string) string {
+	return fmt.Sprintf("%s/json/%s", dir, Slug(city));
+}
+
+// Stores a copy of the published JSON data of a week in the archive
+//
+func ArchiveOutput(dir string, city string, year int, week int, outData []byte) os.Error {
+	if err := os.MkdirAll(OutputDir(dir, city), 0755); err != nil {
+		return err;
+	}
+	return ioutil.WriteFile(archivedWeekPath(dir, city, year, week), outData, 0644);
+}
+
+// Returns the path of an archived week, like 2011-W07.json
+//
+func archivedWeekPath(dir string, city string, year int, week int) string {
+	return fmt.Sprintf("%s/%d-W%02d.json", OutputDir(dir, city), year, week);
+}
+
+// Reads all archived weeks of a city, keyed by their WeekKey. Weeks
+// archived before the year was part of the names (v7.json) are read as
+// well, unless the week is also archived under its new name, but they
+// are left as they are, see MigrateArchive.
+//
+func ReadArchivedWeeks(dir string, city string) (map[int]*DataStruct, os.Error) {
+	var weeks = make(map[int]*DataStruct);
+
+	files, err := ioutil.ReadDir(OutputDir(dir, city));
+	if err != nil {
+		return nil, err;
+	}
+
+	for i := 0; i < len(files); i++ {
+		var name = files[i].Name;
+		year, week, old := archivedWeek(files[i]);
+		if week == 0 {
+			continue;
+		}
+		var key = WeekKey(int64(year), week);
+		if _, ok := weeks[key]; ok && old {
+			continue;
+		}
+
+		data, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", OutputDir(dir, city), name));
+		if err != nil {
+			return nil, err;
+		}
+		var weekData = new(DataStruct);
+		if err = json.Unmarshal(data, weekData); err != nil {
+			return nil, os.NewError(name + ": " + err.String());
+		}
+		weekData.Year = year;
+		weeks[key] = weekData;
+	}
+	return weeks, nil;
+}
+
+// Renames the weeks of all cities archived before the year was part of
+// the names, like v7.json, to 2011-W07.json. Weeks that are also
+// archived under their new name are left for a human to sort out.
+//
+func MigrateArchive(dir string) os.Error {
+	cities, err := ioutil.ReadDir(fmt.Sprintf("%s/json", dir));
+	if err != nil {
+		return err;
+	}
+
+	for i := 0; i < len(cities); i++ {
+		if !cities[i].IsDirectory() {
+			continue;
+		}
+		var cityDir = fmt.Sprintf("%s/json/%s", dir, cities[i].Name);
+		files, err := ioutil.ReadDir(cityDir);
+		if err != nil {
+			return err;
+		}
+
+		for j := 0; j < len(files); j++ {
+			year, week, old := archivedWeek(files[j]);
+			if week == 0 || !old {
+				continue;
+			}
+			var file = fmt.Sprintf("%s/%s", cityDir, files[j].Name);
+			var migrated = fmt.Sprintf("%s/%d-W%02d.json", cityDir, year, week);
+			if _, err := os.Stat(migrated); err == nil {
+				log.Printf("Not migrating %s, %s already exists\n", file, migrated);
+				continue;
+			}
+			if err = os.Rename(file, migrated); err != nil {
+				return err;
+			}
+			log.Printf("Migrated %s to %s\n", file, migrated);
+		}
+	}
+	return nil;
+}
+
+// Returns the year and week of an archived week from its file name, and
+// if it's an old name without the year. The week is 0 for other files.
+//
+func archivedWeek(info *os.FileInfo) (int, int, bool) {
+	var name = info.Name;
+	if !strings.HasSuffix(name, ".json") {
+		return 0, 0, false;
+	}
+
+	var parts = strings.Split(name[0:len(name) - 5], "-W", 2);
+	switch {
+	case len(parts) == 2:
+		year, err := strconv.Atoi(parts[0]);
+		if err != nil {
+			return 0, 0, false;
+		}
+		week, err := strconv.Atoi(parts[1]);
+		if err != nil {
+			return 0, 0, false;
+		}
+		return year, week, false;
+	case strings.HasPrefix(name, "v"):
+		week, err := strconv.Atoi(parts[0][1:]);
+		if err != nil {
+			return 0, 0, false;
+		}
+		return archivedYear(info, week), week, true;
+	}
+	return 0, 0, false;
+}
+
+// Returns the year of a week archived without one, the year of the week
+// the file was written in, or the one before or after if the week is
+// more than half a year away from it
+//
+func archivedYear(info *os.FileInfo, week int) int {
+	var year, written = WeekOf(time.SecondsToLocalTime(info.Mtime_ns / 1e9));
+	switch {
+	case week - written > 26:
+		year--;
+	case written - week > 26:
+		year++;
+	}
+	return int(year);
+}
+
+// Returns a report line for every restaurant in the archive that hasn't
+// appeared during the last n weeks before the current week, given by its
+// WeekKey
+//
+func DeadRestaurants(weeks map[int]*DataStruct, current int, n int) []string {
+	var lastSeen = make(map[string]int);
+	var names = make(map[string]string);
+
+	for key, data := range weeks {
+		for day := 0; day < 5; day++ {
+			var list = append(data.Days[day].Restaurants, data.Days[day].Specials...);
+			for i := 0; i < len(list); i++ {
+				if key > lastSeen[list[i].Id] {
+					lastSeen[list[i].Id] = key;
+					names[list[i].Id] = list[i].Name;
+				}
+			}
+		}
+	}
+
+	var report = make([]string, 0);
+	for id, key := range lastSeen {
+		if current - key >= n {
+			var data = weeks[key];
+			report = append(report, fmt.Sprintf("%s (%s) last seen week %d-W%02d", id, names[id], data.Year, data.Week));
+		}
+	}
+	sort.SortStrings(report);
+	return report;
+}
+
+// Appends a line about a change in the data of a city and week to the
+// changelog of the archive
+//
+func AppendChangelog(dir string, data *DataStruct, text string) {
+	fmt.Printf("CHANGE: %s\n", text);
+
+	f, err := os.Open(fmt.Sprintf("%s/changelog", dir), os.O_WRONLY | os.O_CREAT | os.O_APPEND, 0644);
+	if err != nil {
+		log.Println(err);
+		return;
+	}
+	defer f.Close();
+
+	var now = time.LocalTime().Format("2006-01-02 15:04");
+	fmt.Fprintf(f, "%s %s %04d-W%02d: %s\n", now, data.City, data.Year, data.Week, text);
+}
@@ -0,0 +1,175 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Backfill of earlier weeks into the archive ("backfill <weeks>"), so
+    a freshly set up archive has history to compare with. Weeks with
+    archived HTML are re-parsed, the others are downloaded with the usual
+    throttling.
+    Re-parsing of the archived weeks (-reparse) after parser fixes.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Parses a week range like 20..32, or a single week
+//
+func ParseWeekRange(s string) (int, int, os.Error) {
+	var parts = strings.Split(s, "..", 2);
+	first, err := strconv.Atoi(strings.TrimSpace(parts[0]));
+	if err != nil {
+		return 0, 0, os.NewError("invalid week range " + s);
+	}
+	var last = first;
+	if len(parts) == 2 {
+		if last, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil {
+			return 0, 0, os.NewError("invalid week range " + s);
+		}
+	}
+	if first < 1 || last > 53 || first > last {
+		return 0, 0, os.NewError("invalid week range " + s);
+	}
+	return first, last, nil;
+}
+
+// Parses an ISO week like 2024-W01, or a week number alone in the given
+// year, into its WeekKey
+//
+func ParseWeek(s string, year int) (int, os.Error) {
+	var parts = strings.Split(strings.TrimSpace(s), "-W", 2);
+	if len(parts) == 2 {
+		var err os.Error;
+		if year, err = strconv.Atoi(parts[0]); err != nil {
+			return 0, os.NewError("invalid week " + s);
+		}
+		parts = parts[1:];
+	}
+	week, err := strconv.Atoi(parts[0]);
+	if err != nil || week < 1 || week > 53 {
+		return 0, os.NewError("invalid week " + s);
+	}
+	return WeekKey(int64(year), week), nil;
+}
+
+// Fetches or re-parses a week and stores the result in the archive
+//
+func Backfill(dir string, week int, providers []string, weekdays []string, dayNames []string, rules *Robots) os.Error {
+	var history = make(map[int]*DataStruct);
+	if weeks, err := ReadArchivedWeeks(dir, *city); err == nil {
+		history = weeks;
+	}
+	warnings = make([]Warning, 0);
+
+	var data *DataStruct;
+	if previous, found := history[WeekKey(int64(*year), week)]; found && HasSnapshots(previous) {
+		fmt.Printf("Re-parsing week %d from the archive\n", week);
+		data = ReparseWeek(dir, previous, history);
+	} else {
+		fmt.Printf("Downloading information for %s and week %d\n", *city, week);
+		data = FetchWeek(week, providers, weekdays, dayNames, rules, history, "");
+	}
+
+	if !*noWarnings {
+		data.Warnings = warnings;
+	}
+	outData, err := EncodeOutput(data);
+	if err != nil {
+		return err;
+	}
+	return ArchiveOutput(dir, *city, data.Year, week, outData);
+}
+
+// Tells if any day of the week references an archived HTML document
+//
+func HasSnapshots(data *DataStruct) bool {
+	for day := 0; day < 5; day++ {
+		if data.Days[day].Snapshot != "" {
+			return true;
+		}
+	}
+	return false;
+}
+
+// Parses the archived HTML documents of a week again with the current
+// parser and configuration. Days without a document keep their error.
+// Only the document of the first provider is archived for a day, the
+// restaurants merged from other providers are lost.
+//
+func ReparseWeek(dir string, previous *DataStruct, history map[int]*DataStruct) *DataStruct {
+	var data = new(DataStruct);
+	data.SchemaVersion = SchemaVersion;
+	data.Generator = VersionString();
+	data.City = previous.City;
+	data.Year = previous.Year;
+	data.Week = previous.Week;
+
+	for day := 0; day < 5; day++ {
+		var dayData = &data.Days[day];
+		dayData.Day = day;
+		dayData.Name = previous.Days[day].Name;
+		dayData.Date = DayDate(int64(data.Year), data.Week, day);
+		dayData.Holiday = previous.Days[day].Holiday;
+		dayData.Snapshot = previous.Days[day].Snapshot;
+		dayData.Error = previous.Days[day].Error;
+
+		if dayData.Snapshot == "" {
+			continue;
+		}
+		inData, err := ioutil.ReadFile(SnapshotPath(dir, dayData.Snapshot));
+		if err != nil {
+			dayData.Error = "archive";
+			Warn("missing-snapshot", day, "", fmt.Sprintf("Archived HTML of day %d is missing: %s", day, err));
+			continue;
+		}
+		ParseDay(dayData, strings.Split(*providerName, ",", 2)[0], int64(data.Year), data.Week, inData, history);
+	}
+
+	data.Summary = Summarize(data);
+	MarkWeeklyMenus(data, *dedupWeekly);
+	return data;
+}
+
+// Re-parses all archived weeks of the city from the week with the given
+// WeekKey on, so fixes to the parser or the configuration also apply to
+// the history
+//
+func Reparse(dir string, since int) os.Error {
+	history, err := ReadArchivedWeeks(dir, *city);
+	if err != nil {
+		return err;
+	}
+
+	var count = 0;
+	for key, previous := range history {
+		if key < since || !HasSnapshots(previous) {
+			continue;
+		}
+		warnings = make([]Warning, 0);
+
+		var data = ReparseWeek(dir, previous, history);
+		if !*noWarnings {
+			data.Warnings = warnings;
+		}
+		outData, err := EncodeOutput(data);
+		if err != nil {
+			log.Printf("ERROR: Week %d-W%02d not re-parsed, invalid data: %s\n", data.Year, data.Week, err);
+			continue;
+		}
+		if err = ArchiveOutput(dir, *city, data.Year, data.Week, outData); err != nil {
+			return err;
+		}
+		count++;
+	}
+	fmt.Printf("Re-parsed %d weeks of %s\n", count, *city);
+	return nil;
+}
@@ -0,0 +1,184 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Benchmarks of parsing and serializing over the archived HTML documents
+    (-bench), compared to a stored baseline to catch regressions
+    (-bench-baseline), and CPU and memory profiling of real runs
+    (-cpuprofile and -memprofile), so that performance work can be
+    measured.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"json"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+)
+
+type BenchResult struct {
+	Name string;			// "parse" or "serialize"
+	File string;
+	Size int;
+	Iterations int;
+	NsPerOp int64;
+	BytesPerOp int64;
+}
+
+// Runs an operation the given number of times and measures the time and
+// the memory allocated per operation
+//
+func measure(iterations int, op func()) (nsPerOp int64, bytesPerOp int64) {
+	runtime.GC();
+	var allocated = runtime.MemStats.TotalAlloc;
+	var start = time.Nanoseconds();
+
+	for i := 0; i < iterations; i++ {
+		op();
+	}
+
+	var elapsed = time.Nanoseconds() - start;
+	return elapsed / int64(iterations), int64(runtime.MemStats.TotalAlloc - allocated) / int64(iterations);
+}
+
+// Benchmarks parsing and serializing of every archived HTML document in
+// the archive directory, smallest document first
+//
+func Bench(dir string, iterations int) ([]BenchResult, os.Error) {
+	files, err := ioutil.ReadDir(fmt.Sprintf("%s/html", dir));
+	if err != nil {
+		return nil, err;
+	}
+
+	var names = make([]string, 0, len(files));
+	var sizes = make(map[string]int64);
+	for i := 0; i < len(files); i++ {
+		if strings.HasSuffix(files[i].Name, ".html") {
+			names = append(names, files[i].Name);
+			sizes[files[i].Name] = files[i].Size;
+		}
+	}
+	sort.SortStrings(names);
+	sort.Sort(&bySize{ names, sizes });
+
+	var results = make([]BenchResult, 0, 2 * len(names));
+	for _, name := range names {
+		inData, err := ioutil.ReadFile(fmt.Sprintf("%s/html/%s", dir, name));
+		if err != nil {
+			return nil, err;
+		}
+
+		var restaurants []RestData;
+		ns, bytes := measure(iterations, func() {
+			restaurants, _ = Parse(inData);
+		});
+		results = append(results, BenchResult{ "parse", name, len(inData), iterations, ns, bytes });
+
+		ns, bytes = measure(iterations, func() {
+			json.Marshal(restaurants);
+		});
+		results = append(results, BenchResult{ "serialize", name, len(inData), iterations, ns, bytes });
+	}
+	return results, nil;
+}
+
+// Prints the results of a benchmark as a table
+//
+func PrintBench(results []BenchResult) {
+	for _, r := range results {
+		fmt.Printf("%-10s %-40s %8d bytes %6d x %12d ns/op %10d B/op\n", r.Name, r.File, r.Size, r.Iterations, r.NsPerOp, r.BytesPerOp);
+	}
+}
+
+// Writes the results of a benchmark as the baseline of later runs
+//
+func WriteBaseline(path string, results []BenchResult) os.Error {
+	data, err := json.Marshal(results);
+	if err != nil {
+		return err;
+	}
+	return ioutil.WriteFile(path, data, 0644);
+}
+
+// Compares the results of a benchmark to the baseline and returns the
+// operations that got slower or allocate more by more than the threshold
+// (0.2 for 20%). Documents missing from either are skipped.
+//
+func CompareBaseline(path string, results []BenchResult, threshold float64) ([]string, os.Error) {
+	data, err := ioutil.ReadFile(path);
+	if err != nil {
+		return nil, err;
+	}
+	var baseline []BenchResult;
+	if err = json.Unmarshal(data, &baseline); err != nil {
+		return nil, os.NewError(path + ": " + err.String());
+	}
+
+	var before = make(map[string]BenchResult);
+	for _, b := range baseline {
+		before[b.Name + " " + b.File] = b;
+	}
+
+	var regressions = make([]string, 0);
+	for _, r := range results {
+		b, found := before[r.Name + " " + r.File];
+		if !found {
+			continue;
+		}
+		if float64(r.NsPerOp) > float64(b.NsPerOp) * (1 + threshold) {
+			regressions = append(regressions, fmt.Sprintf("%s %s: %d ns/op, was %d", r.Name, r.File, r.NsPerOp, b.NsPerOp));
+		}
+		if float64(r.BytesPerOp) > float64(b.BytesPerOp) * (1 + threshold) {
+			regressions = append(regressions, fmt.Sprintf("%s %s: %d B/op, was %d", r.Name, r.File, r.BytesPerOp, b.BytesPerOp));
+		}
+	}
+	return regressions, nil;
+}
+
+// Sorts file names by the size of the files
+//
+type bySize struct {
+	names []string;
+	sizes map[string]int64;
+}
+
+func (s *bySize) Len() int		{ return len(s.names); }
+func (s *bySize) Less(i, j int) bool	{ return s.sizes[s.names[i]] < s.sizes[s.names[j]]; }
+func (s *bySize) Swap(i, j int)		{ s.names[i], s.names[j] = s.names[j], s.names[i]; }
+
+// Starts CPU profiling to the file, the returned function stops it
+//
+func StartCPUProfile(path string) (func(), os.Error) {
+	f, err := os.Open(path, os.O_WRONLY | os.O_CREAT | os.O_TRUNC, 0644);
+	if err != nil {
+		return nil, err;
+	}
+	if err = pprof.StartCPUProfile(f); err != nil {
+		f.Close();
+		return nil, err;
+	}
+	return func() {
+		pprof.StopCPUProfile();
+		f.Close();
+	}, nil;
+}
+
+// Writes a heap profile to the file
+//
+func WriteMemProfile(path string) os.Error {
+	f, err := os.Open(path, os.O_WRONLY | os.O_CREAT | os.O_TRUNC, 0644);
+	if err != nil {
+		return err;
+	}
+	defer f.Close();
+	return pprof.WriteHeapProfile(f);
+}
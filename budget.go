@@ -0,0 +1,90 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Size budget of the output (-max-bytes) for app users on capped mobile
+    plans. A week over the budget loses its descriptions first, then the
+    longest menus one at a time, and what was left out is listed in the
+    output so the app can say so. Only the published file is trimmed, the
+    archive keeps the whole week.
+*/
+
+package main
+
+import (
+	"fmt"
+	"json"
+	"log"
+	"os"
+)
+
+// Makes an encoded week fit within the budget in bytes, 0 for no
+// budget. The descriptions and menus are dropped from a copy decoded
+// from it, the week itself is left whole for the archive and the other
+// outputs. Returns the data even if it doesn't fit after everything
+// that can be dropped is dropped.
+//
+func EncodeWithin(outData []byte, budget int) ([]byte, os.Error) {
+	if budget <= 0 || len(outData) <= budget {
+		return outData, nil;
+	}
+
+	var data = new(DataStruct);
+	if err := json.Unmarshal(outData, data); err != nil {
+		return nil, err;
+	}
+
+	fmt.Printf("Output is %d bytes, over the budget of %d, dropping descriptions\n", len(outData), budget);
+	for day := 0; day < 5; day++ {
+		dropDescriptions(data.Days[day].Restaurants);
+		dropDescriptions(data.Days[day].Specials);
+	}
+	data.Dropped = append(data.Dropped, "descriptions");
+	outData, err := EncodeOutput(data);
+	if err != nil || len(outData) <= budget {
+		return outData, err;
+	}
+
+	for {
+		var r, day = longestMenu(data);
+		if r == nil {
+			break;
+		}
+		fmt.Printf("Output is %d bytes, dropping the menu of %s on %s\n", len(outData), r.Id, data.Days[day].Name);
+		r.Menu = "";
+		r.Dishes = nil;
+		data.Dropped = append(data.Dropped, fmt.Sprintf("menu of %s on day %d", r.Id, day));
+		if outData, err = EncodeOutput(data); err != nil || len(outData) <= budget {
+			return outData, err;
+		}
+	}
+
+	log.Printf("WARNING: Output is %d bytes with all menus dropped, over the budget of %d\n", len(outData), budget);
+	return outData, nil;
+}
+
+func dropDescriptions(list []RestData) {
+	for i := 0; i < len(list); i++ {
+		list[i].Description = "";
+	}
+}
+
+// Returns the restaurant with the longest menu of the week and its day,
+// nil if no menus are left
+//
+func longestMenu(data *DataStruct) (*RestData, int) {
+	var longest *RestData;
+	var longestDay = 0;
+	for day := 0; day < 5; day++ {
+		for _, list := range [][]RestData{ data.Days[day].Restaurants, data.Days[day].Specials } {
+			for i := 0; i < len(list); i++ {
+				if list[i].Menu != "" && (longest == nil || len(list[i].Menu) > len(longest.Menu)) {
+					longest, longestDay = &list[i], day;
+				}
+			}
+		}
+	}
+	return longest, longestDay;
+}
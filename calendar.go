@@ -0,0 +1,133 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Date calculations for the weeks in Lunchguiden and a calendar of the
+    Swedish public holidays ("röda dagar").
+*/
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const daySeconds = 24 * 60 * 60;
+
+// Returns the date of a weekday (0 = monday) in the given ISO week
+//
+func WeekdayDate(year int64, week int, day int) *time.Time {
+
+	// January 4th is always in week 1, so find the monday before it
+	//
+	var jan4 = &time.Time{ Year: year, Month: 1, Day: 4 };
+	var secs = jan4.Seconds();
+	var offset = (time.SecondsToUTC(secs).Weekday + 6) % 7;
+
+	secs += int64((week - 1) * 7 + day - offset) * daySeconds;
+	return time.SecondsToUTC(secs);
+}
+
+// Returns the calendar date of a weekday in an ISO week as YYYY-MM-DD.
+// WeekdayDate gives midnight UTC of the date, so the date is the same in
+// Stockholm and wherever the program runs.
+//
+func DayDate(year int64, week int, day int) string {
+	return WeekdayDate(year, week, day).Format("2006-01-02");
+}
+
+// Returns a number for an ISO week that is unique across years, the
+// weeks since the one of the epoch, so the week before is always one
+// less. The epoch is a thursday, three days after the monday of its week.
+//
+func WeekKey(year int64, week int) int {
+	return int((WeekdayDate(year, week, 0).Seconds() + 3 * daySeconds) / daySeconds / 7);
+}
+
+// Returns the ISO week and its year of a date
+//
+func WeekOf(t *time.Time) (int64, int) {
+	var date = (&time.Time{ Year: t.Year, Month: t.Month, Day: t.Day }).Seconds();
+
+	for year := t.Year + 1; year >= t.Year - 1; year-- {
+		var monday = WeekdayDate(year, 1, 0).Seconds();
+		if date >= monday {
+			return year, int((date - monday) / daySeconds / 7) + 1;
+		}
+	}
+	return t.Year, 1;
+}
+
+// Computes the date of easter sunday using the anonymous gregorian
+// algorithm, returned as seconds since the epoch
+//
+func Easter(year int64) int64 {
+	var a = year % 19;
+	var b = year / 100;
+	var c = year % 100;
+	var d = b / 4;
+	var e = b % 4;
+	var f = (b + 8) / 25;
+	var g = (b - f + 1) / 3;
+	var h = (19 * a + b - d - g + 15) % 30;
+	var i = c / 4;
+	var k = c % 4;
+	var l = (32 + 2 * e + 2 * i - h - k) % 7;
+	var m = (a + 11 * h + 22 * l) / 451;
+	var month = (h + l - 7 * m + 114) / 31;
+	var day = ((h + l - 7 * m + 114) % 31) + 1;
+
+	var t = &time.Time{ Year: year, Month: int(month), Day: int(day) };
+	return t.Seconds();
+}
+
+// Returns all holidays of a year, keyed by "MM-DD". The de facto holidays
+// (midsummer eve, christmas eve and new year's eve) are included since
+// practically no restaurants serve lunch on those days either.
+//
+func Holidays(year int64) map[string]string {
+	var holidays = map[string]string {
+		"01-01": "Nyårsdagen",
+		"01-06": "Trettondedag jul",
+		"05-01": "Första maj",
+		"06-06": "Sveriges nationaldag",
+		"12-24": "Julafton",
+		"12-25": "Juldagen",
+		"12-26": "Annandag jul",
+		"12-31": "Nyårsafton",
+	};
+
+	// Holidays relative to easter sunday
+	//
+	var easter = Easter(year);
+	holidays[DateKey(time.SecondsToUTC(easter - 2 * daySeconds))]  = "Långfredagen";
+	holidays[DateKey(time.SecondsToUTC(easter + 1 * daySeconds))]  = "Annandag påsk";
+	holidays[DateKey(time.SecondsToUTC(easter + 39 * daySeconds))] = "Kristi himmelsfärdsdag";
+
+	// Midsummer eve is the friday between June 19th and 25th
+	//
+	for day := 19; day <= 25; day++ {
+		var t = &time.Time{ Year: year, Month: 6, Day: day };
+		if time.SecondsToUTC(t.Seconds()).Weekday == 5 {
+			holidays[DateKey(t)] = "Midsommarafton";
+		}
+	}
+
+	return holidays;
+}
+
+// Returns the name of the holiday on the given date, or an empty
+// string for ordinary days
+//
+func HolidayName(t *time.Time) string {
+	return Holidays(t.Year)[DateKey(t)];
+}
+
+// Returns the "MM-DD" key of a date used by the holiday calendar
+//
+func DateKey(t *time.Time) string {
+	return fmt.Sprintf("%02d-%02d", t.Month, t.Day);
+}
@@ -0,0 +1,87 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Menus of internal canteens (-canteen), from a JSON file maintained by
+    hand, merged into the restaurants of the city with Source "internal"
+    so employees see everything in one feed. The file is a list like:
+
+    [
+        {
+            "Name": "Personalrestaurangen",
+            "Description": "Plan 2, lunch serveras 11-13",
+            "ImageUrl": "",
+            "Menus": [ "Köttbullar\nPotatismos", "", "", "", "Fiskgratäng" ]
+        }
+    ]
+
+    The menus are given per weekday, Monday first, empty for no lunch.
+    Only JSON is read, not YAML or iCalendar.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"json"
+	"os"
+	"strings"
+)
+
+type Canteen struct {
+	Id string;			// Defaults to the slug of the name
+	Name string;
+	Description string;
+	ImageUrl string;
+	Menus []string;
+}
+
+// Adds the canteens with a menu to the days of the week, prepared like
+// the restaurants parsed from the providers. The summary and the weekly
+// menus are left to the caller, see FetchWeek.
+//
+func MergeCanteens(data *DataStruct, file string) os.Error {
+	input, err := ioutil.ReadFile(file);
+	if err != nil {
+		return err;
+	}
+	var canteens []Canteen;
+	if err = json.Unmarshal(input, &canteens); err != nil {
+		return os.NewError(file + ": " + err.String());
+	}
+
+	for day := 0; day < 5; day++ {
+		var dayData = &data.Days[day];
+		if dayData.Holiday != "" && *holidays == "skip" {
+			continue;
+		}
+
+		var list = make([]RestData, 0, len(canteens));
+		for i := 0; i < len(canteens); i++ {
+			var c = &canteens[i];
+			if day >= len(c.Menus) || strings.TrimSpace(c.Menus[day]) == "" {
+				continue;
+			}
+
+			var r = RestData{ Id: c.Id, Name: c.Name, ImageUrl: c.ImageUrl, Description: c.Description, Source: "internal" };
+			if r.Id == "" {
+				r.Id = Slug(c.Name);
+			}
+			r.Menu = strings.TrimSpace(c.Menus[day]);
+			CompleteText(&r);
+			list = append(list, r);
+		}
+		if len(list) == 0 {
+			continue;
+		}
+
+		dayData.Restaurants = append(dayData.Restaurants, PrepareRestaurants(list)...);
+		if *specials {
+			SplitSpecials(dayData);
+		}
+		OrderRestaurants(dayData.Restaurants, *order, strings.Split(*favorites, ",", -1));
+	}
+	return nil;
+}
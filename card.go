@@ -0,0 +1,91 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    The "today card" (-today-card), a tiny file with the first dishes of
+    a few favorite restaurants today, for home screen widgets refreshing
+    on a strict data budget. Its format is kept stable on purpose.
+*/
+
+package main
+
+import (
+	"json"
+	"os"
+	"time"
+)
+
+type TodayCard struct {
+	City string;
+	Week int;
+	Day int;
+	Name string;
+	Restaurants []CardRestaurant;
+}
+type CardRestaurant struct {
+	Id string;
+	Name string;
+	Dishes []string;
+}
+
+// Number of dishes per restaurant on the card
+//
+const cardDishes = 2;
+
+// Writes the card of today to the file, with the first n favorites, or
+// the first n restaurants without favorites. Outside of the week the
+// card shows Monday, and so do the weekly menus only kept on Monday.
+//
+func WriteTodayCard(file string, data *DataStruct, year int64, n int, favorites []string) os.Error {
+	var day = Today(data.Week, year);
+	var card = TodayCard{ City: data.City, Week: data.Week, Day: day, Name: data.Days[day].Name };
+	card.Restaurants = make([]CardRestaurant, 0, n);
+
+	var list = make([]RestData, len(data.Days[day].Restaurants));
+	copy(list, data.Days[day].Restaurants);
+	if len(favorites) > 0 && favorites[0] != "" {
+		OrderRestaurants(list, "favorites", favorites);
+		list = list[0:countFavorites(list, favorites)];
+	}
+
+	for i := 0; i < len(list) && len(card.Restaurants) < n; i++ {
+		var r = CardRestaurant{ Id: list[i].Id, Name: list[i].Name, Dishes: make([]string, 0, cardDishes) };
+		var dishes = WeeklyDishes(data, &list[i]);
+		for j := 0; j < len(dishes) && j < cardDishes; j++ {
+			r.Dishes = append(r.Dishes, dishes[j].Text);
+		}
+		card.Restaurants = append(card.Restaurants, r);
+	}
+
+	output, err := json.Marshal(card);
+	if err != nil {
+		return err;
+	}
+	return WriteFile(file, output);
+}
+
+// Returns the number of favorites at the start of the ordered list
+//
+func countFavorites(list []RestData, favorites []string) int {
+	var n = 0;
+	for n < len(list) && favoriteRank(list[n].Id, favorites) < len(favorites) {
+		n++;
+	}
+	return n;
+}
+
+// Returns the day of the week that is today, or Monday if today isn't
+// in the week
+//
+func Today(week int, year int64) int {
+	var now = time.LocalTime();
+	for day := 0; day < 5; day++ {
+		var t = WeekdayDate(year, week, day);
+		if DateKey(t) == DateKey(now) && t.Year == now.Year {
+			return day;
+		}
+	}
+	return 0;
+}
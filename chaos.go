@@ -0,0 +1,90 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Fault injection in the downloads (-chaos), for trying out in staging
+    how runs handle timeouts, server errors, truncated documents and slow
+    responses. Never for production, every injected fault is logged.
+
+      -chaos timeout=0.1,error=0.1,truncate=0.2,slow=0.1
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Probability of every kind of fault, per request
+//
+var chaos = make(map[string]float64);
+var chaosKinds = []string{ "timeout", "error", "truncate", "slow" };
+
+// Longest delay of a slow response, in nanoseconds
+//
+const chaosSlowest = 30e9;
+
+// Sets up the faults from a comma separated list of kind=probability
+//
+func SetupChaos(spec string) os.Error {
+	if spec == "" {
+		return nil;
+	}
+	for _, part := range strings.Split(spec, ",", -1) {
+		var pair = strings.Split(part, "=", 2);
+		if len(pair) != 2 || !contains(chaosKinds, strings.TrimSpace(pair[0])) {
+			return os.NewError("invalid fault " + part);
+		}
+		p, err := strconv.Atof64(strings.TrimSpace(pair[1]));
+		if err != nil || p < 0 || p > 1 {
+			return os.NewError("invalid fault probability " + part);
+		}
+		chaos[strings.TrimSpace(pair[0])] = p;
+	}
+	fmt.Println("WARNING: Faults are injected in the downloads (-chaos)");
+	return nil;
+}
+
+// Picks the fault of a request, an empty string for none. The kinds are
+// tried in order so their probabilities don't add up.
+//
+func pickFault(url string) string {
+	for _, kind := range chaosKinds {
+		if p, found := chaos[kind]; found && rand.Float64() < p {
+			fmt.Printf("CHAOS: %s for %s\n", kind, url);
+			return kind;
+		}
+	}
+	return "";
+}
+
+// Injects the fault picked for a request before it's made. Returns an
+// error for the faults that replace the response.
+//
+func injectFault(fault string, url string) os.Error {
+	switch fault {
+	case "timeout":
+		return &TimeoutError{ url, 0 };
+	case "error":
+		return &StatusError{ url, "500 Internal Server Error (injected)" };
+	case "slow":
+		time.Sleep(rand.Int63n(chaosSlowest));
+	}
+	return nil;
+}
+
+// Cuts a document short if that's the fault of the request
+//
+func truncateFault(fault string, data []byte) []byte {
+	if fault != "truncate" || len(data) == 0 {
+		return data;
+	}
+	return data[0:rand.Intn(len(data))];
+}
@@ -0,0 +1,124 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Detection of the character set of the downloaded documents and
+    transcoding to UTF-8. Lunchguiden serves ISO-8859-1 without always
+    saying so, which is where the mojibake in the menus came from.
+*/
+
+package main
+
+import (
+	"bytes"
+	"http"
+	"regexp"
+	"strings"
+	"utf8"
+)
+
+var rx_charset = regexp.MustCompile("charset=[\"']?([a-zA-Z0-9_\\-]+)");
+
+// Characters 0x80 to 0x9f of windows-1252, which are control characters
+// in ISO-8859-1
+//
+var windows1252 = []int {
+	0x20ac, 0xfffd, 0x201a, 0x0192, 0x201e, 0x2026, 0x2020, 0x2021,
+	0x02c6, 0x2030, 0x0160, 0x2039, 0x0152, 0xfffd, 0x017d, 0xfffd,
+	0xfffd, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014,
+	0x02dc, 0x2122, 0x0161, 0x203a, 0x0153, 0xfffd, 0x017e, 0x0178,
+};
+
+// Returns the character set of a document. An override wins over the
+// Content-Type header, which wins over a <meta> tag. Without any of them
+// the document is taken as UTF-8 if it's valid UTF-8, otherwise as
+// ISO-8859-1.
+//
+func DetectCharset(override string, contentType string, data []byte) string {
+	if override != "" {
+		return strings.ToLower(override);
+	}
+	if match := rx_charset.FindStringSubmatch(contentType); len(match) > 0 {
+		return strings.ToLower(match[1]);
+	}
+
+	var head = data;
+	if len(head) > 1024 {
+		head = head[0:1024];
+	}
+	if match := rx_charset.FindSubmatch(head); len(match) > 0 {
+		return strings.ToLower(string(match[1]));
+	}
+
+	if validUTF8(data) {
+		return "utf-8";
+	}
+	return "iso-8859-1";
+}
+
+// Converts the document from the character set to UTF-8, unknown
+// character sets are left as they are
+//
+func ToUTF8(data []byte, charset string) []byte {
+	switch charset {
+	case "iso-8859-1", "latin1", "latin-1", "iso8859-1", "windows-1252", "cp1252":
+	default:
+		return data;
+	}
+
+	var buf = bytes.NewBuffer(make([]byte, 0, len(data) + len(data) / 8));
+	for _, b := range data {
+		var c = int(b);
+		if c >= 0x80 && c <= 0x9f && (charset == "windows-1252" || charset == "cp1252") {
+			c = windows1252[c - 0x80];
+		}
+		buf.WriteRune(c);
+	}
+	return buf.Bytes();
+}
+
+func validUTF8(data []byte) bool {
+	for len(data) > 0 {
+		c, size := utf8.DecodeRune(data);
+		if c == utf8.RuneError && size == 1 {
+			return false;
+		}
+		data = data[size:];
+	}
+	return true;
+}
+
+// URL escapes a value in the character set of the site, UTF-8 unless
+// it's one of the single byte sets. Characters missing in the character
+// set are replaced by a question mark. Values that aren't UTF-8 are
+// taken to be in the character set of the site already, like the ones
+// given with -days.
+//
+func EscapeValue(value string, charset string) string {
+	charset = strings.ToLower(charset);
+	switch {
+	case !validUTF8([]byte(value)):
+		return http.URLEscape(value);
+	case charset == "iso-8859-1", charset == "latin1", charset == "latin-1", charset == "iso8859-1", charset == "windows-1252", charset == "cp1252":
+	default:
+		return http.URLEscape(value);
+	}
+
+	var encoded = make([]byte, 0, len(value));
+	for _, c := range value {
+		if charset == "windows-1252" || charset == "cp1252" {
+			for i := 0; i < len(windows1252); i++ {
+				if windows1252[i] == c {
+					c = 0x80 + i;
+				}
+			}
+		}
+		if c > 0xff {
+			c = '?';
+		}
+		encoded = append(encoded, byte(c));
+	}
+	return http.URLEscape(string(encoded));
+}
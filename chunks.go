@@ -0,0 +1,168 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Content addressed pieces of an export (-export-chunks), so a mirror
+    only downloads what changed since the last export. The export is cut
+    where a rolling checksum of the last bytes hits a pattern, so a change
+    only changes the pieces around it, and every piece is stored in the
+    chunks directory next to the export named by its md5. The index lists
+    the pieces in order:
+
+      # lunchguiden chunks <size> <md5 of the export>
+      <md5 of the piece> <size>
+      ...
+
+    A mirror fetches the index, downloads the pieces it doesn't have and
+    imports the index with -import, which puts the export together again.
+    Pieces are never removed, old ones can be cleaned out by hand.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Window of the rolling checksum and the limits of the piece sizes, the
+// pieces are 8 KiB on average
+//
+const (
+	chunkWindow = 64;
+	chunkMask = 1 << 13 - 1;
+	chunkMin = 2 << 10;
+	chunkMax = 64 << 10;
+)
+
+// Cuts the file into pieces stored in the chunks directory next to it
+// and writes the index to the file name with .chunks added
+//
+func WriteChunks(file string) os.Error {
+	data, err := ioutil.ReadFile(file);
+	if err != nil {
+		return err;
+	}
+	var dir = path.Join(path.Dir(file), "chunks");
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err;
+	}
+
+	var hashStr, _ = GenerateHash(data);
+	var index = bytes.NewBuffer(make([]byte, 0));
+	fmt.Fprintf(index, "# lunchguiden chunks %d %s\n", len(data), hashStr);
+
+	var stored, total = 0, 0;
+	for _, piece := range cutChunks(data) {
+		var pieceHash, _ = GenerateHash(piece);
+		var target = path.Join(dir, pieceHash);
+		if _, err := os.Stat(target); err != nil {
+			if err = ioutil.WriteFile(target, piece, 0644); err != nil {
+				return err;
+			}
+			stored++;
+		}
+		total++;
+		fmt.Fprintf(index, "%s %d\n", pieceHash, len(piece));
+	}
+
+	fmt.Printf("Cut %s into %d pieces, %d of them new\n", file, total, stored);
+	return WriteFile(file + ".chunks", index.Bytes());
+}
+
+// Returns the pieces of the data, cut where the rolling sum of the last
+// bytes matches the mask
+//
+func cutChunks(data []byte) [][]byte {
+	var pieces = make([][]byte, 0);
+	var start = 0;
+	var sum uint32 = 0;
+
+	for i := 0; i < len(data); i++ {
+		sum = sum * 31 + uint32(data[i]);
+		if i - start >= chunkWindow {
+			sum -= uint32(data[i - chunkWindow]) * chunkPower;
+		}
+
+		var size = i + 1 - start;
+		if size >= chunkMin && sum & chunkMask == 0 || size >= chunkMax {
+			pieces = append(pieces, data[start:i + 1]);
+			start = i + 1;
+			sum = 0;
+		}
+	}
+	if start < len(data) {
+		pieces = append(pieces, data[start:]);
+	}
+	return pieces;
+}
+
+// 31 to the power of the window, for taking the byte leaving the window
+// out of the sum
+//
+var chunkPower = func() uint32 {
+	var p uint32 = 1;
+	for i := 0; i < chunkWindow; i++ {
+		p *= 31;
+	}
+	return p;
+}();
+
+// Puts an export together from its index and the pieces in the chunks
+// directory next to it, checking every piece and the whole. Returns the
+// name of the export, the index without .chunks.
+//
+func AssembleChunks(indexFile string) (string, os.Error) {
+	f, err := os.Open(indexFile, os.O_RDONLY, 0);
+	if err != nil {
+		return "", err;
+	}
+	defer f.Close();
+
+	var r = bufio.NewReader(f);
+	header, err := r.ReadString('\n');
+	var fields = strings.Fields(header);
+	if err != nil || len(fields) != 5 || fields[1] != "lunchguiden" || fields[2] != "chunks" {
+		return "", os.NewError(indexFile + ": not an index of pieces");
+	}
+
+	var dir = path.Join(path.Dir(indexFile), "chunks");
+	var data = bytes.NewBuffer(make([]byte, 0));
+	for {
+		line, err := r.ReadString('\n');
+		if err == os.EOF {
+			break;
+		}
+		if err != nil {
+			return "", err;
+		}
+		var entry = strings.Fields(line);
+		if len(entry) != 2 {
+			return "", os.NewError(indexFile + ": invalid line " + line);
+		}
+		piece, err := ioutil.ReadFile(path.Join(dir, path.Base(entry[0])));
+		if err != nil {
+			return "", err;
+		}
+		var pieceHash, _ = GenerateHash(piece);
+		if size, _ := strconv.Atoi(entry[1]); pieceHash != entry[0] || size != len(piece) {
+			return "", os.NewError("piece " + entry[0] + " is damaged");
+		}
+		data.Write(piece);
+	}
+
+	var hashStr, _ = GenerateHash(data.Bytes());
+	if fmt.Sprint(data.Len()) != fields[3] || hashStr != fields[4] {
+		return "", os.NewError(indexFile + ": the pieces don't make up the export");
+	}
+	var file = indexFile[0:len(indexFile) - len(".chunks")];
+	return file, ioutil.WriteFile(file, data.Bytes(), 0644);
+}
@@ -0,0 +1,122 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Client for Go programs consuming the JSON files published by
+    lunchguiden. It downloads a week, verifies it against the .md5 file
+    published next to it, checks that the schema version is supported and
+    returns it as typed structs (model.go, generated from the structs of
+    lunchguiden). Weeks are cached by their ETag so that unchanged files
+    aren't downloaded again. The transport is the one lunchguiden uses.
+
+        var c = client.New();
+        week, err := c.Get("http://example.com/lunchguiden/falun.v33.json");
+*/
+
+package client
+
+import (
+	"crypto/md5"
+	"fmt"
+	"http"
+	"io/ioutil"
+	"json"
+	"os"
+	"strings"
+)
+
+// Newest schema version this client understands
+//
+const SupportedSchema = 1;
+
+type Client struct {
+	Transport *Transport;
+	cache map[string]*cached;
+}
+
+type cached struct {
+	etag string;
+	week *Week;
+}
+
+func New() *Client {
+	return &Client{ Transport: NewTransport(), cache: make(map[string]*cached) };
+}
+
+// Returns the week published at the URL. The cached week is returned if
+// the file hasn't changed since it was last downloaded.
+//
+func (c *Client) Get(url string) (*Week, os.Error) {
+	var etag = "";
+	if entry, found := c.cache[url]; found {
+		etag = entry.etag;
+	}
+
+	res, data, err := c.get(url, etag);
+	if err != nil {
+		return nil, err;
+	}
+	if res.StatusCode == http.StatusNotModified && etag != "" {
+		return c.cache[url].week, nil;
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, os.NewError(url + ": " + res.Status);
+	}
+
+	// The md5 sum is published next to the file
+	//
+	sumRes, sum, err := c.get(url + ".md5", "");
+	if err != nil {
+		return nil, err;
+	}
+	if sumRes.StatusCode != http.StatusOK {
+		return nil, os.NewError(url + ".md5: " + sumRes.Status);
+	}
+	var h = md5.New();
+	h.Write(data);
+	if fmt.Sprintf("%x", h.Sum()) != strings.TrimSpace(string(sum)) {
+		return nil, os.NewError(url + ": md5 sum doesn't match");
+	}
+
+	var week = new(Week);
+	if err = json.Unmarshal(data, week); err != nil {
+		return nil, err;
+	}
+
+	// Files from before the schema was versioned are version 1
+	//
+	if week.SchemaVersion == 0 {
+		week.SchemaVersion = 1;
+	}
+	if week.SchemaVersion > SupportedSchema {
+		return nil, os.NewError(fmt.Sprintf("%s: schema version %d is newer than the supported %d", url, week.SchemaVersion, SupportedSchema));
+	}
+
+	if tag := res.GetHeader("ETag"); tag != "" {
+		c.cache[url] = &cached{ tag, week };
+	}
+	return week, nil;
+}
+
+// Downloads the URL, sending If-None-Match if there is an ETag
+//
+func (c *Client) get(url string, etag string) (*http.Response, []byte, os.Error) {
+	var header = make(map[string]string);
+	if etag != "" {
+		header["If-None-Match"] = etag;
+	}
+
+	res, err := c.Transport.Do("GET", url, header, "");
+	if err != nil {
+		return nil, nil, err;
+	}
+	defer res.Body.Close();
+
+	data, err := ioutil.ReadAll(res.Body);
+	if err != nil {
+		return nil, nil, err;
+	}
+	return res, data, nil;
+}
@@ -0,0 +1,229 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    The HTTP transport of lunchguiden and the client. It's a small one of
+    our own instead of http.Get, so that the TLS settings (custom CA
+    bundles for fetching through an inspecting proxy), the network and
+    the headers of the requests can be controlled. Compressed responses
+    are asked for and decoded, the HTML tables and the JSON files
+    compress very well.
+*/
+
+package client
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/tls"
+	"fmt"
+	"http"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+type Transport struct {
+	TLSConfig *tls.Config;		// Settings for https, the defaults if nil
+	Network string;			// tcp, tcp4 or tcp6
+	LocalAddr string;		// Source address and port of the connections, any if empty
+	UserAgent string;
+	Compressed bool;		// Ask for compressed responses and decode them
+	MaxRedirects int;		// Most redirects followed for one request
+}
+
+// Returns a transport with the defaults of the client
+//
+func NewTransport() *Transport {
+	return &Transport{ Network: "tcp", UserAgent: "lunchguiden-client", Compressed: true, MaxRedirects: 5 };
+}
+
+// Body of a response, closing the connection when closed
+//
+type connBody struct {
+	io.Reader;
+	conn net.Conn;
+}
+
+func (b *connBody) Close() os.Error {
+	return b.conn.Close();
+}
+
+// Makes the request and returns the response, following redirects for
+// GET and HEAD requests. The headers, like Content-Type for the body of
+// a POST, are added to the request. The body of the response must be
+// closed.
+//
+func (t *Transport) Do(method string, rawurl string, header map[string]string, body string) (*http.Response, os.Error) {
+	for redirects := 0; ; redirects++ {
+		res, err := t.roundTrip(method, rawurl, header, body);
+		if err != nil {
+			return nil, &http.URLError{ method, rawurl, err };
+		}
+
+		var location = res.GetHeader("Location");
+		switch {
+		case method != "GET" && method != "HEAD":
+		case res.StatusCode != 301 && res.StatusCode != 302 && res.StatusCode != 303 && res.StatusCode != 307:
+		case location == "":
+		default:
+			res.Body.Close();
+			if redirects == t.MaxRedirects {
+				return nil, &http.URLError{ method, rawurl, os.NewError("too many redirects") };
+			}
+			rawurl = ResolveLocation(rawurl, location);
+			continue;
+		}
+		return res, nil;
+	}
+	return nil, nil;
+}
+
+// Makes a single request over a new connection
+//
+func (t *Transport) roundTrip(method string, rawurl string, header map[string]string, body string) (*http.Response, os.Error) {
+	u, err := http.ParseURL(rawurl);
+	if err != nil {
+		return nil, err;
+	}
+
+	var host = u.Host;
+	var addr = host;
+	if strings.Index(host, ":") < 0 {
+		if u.Scheme == "https" {
+			addr += ":443";
+		} else {
+			addr += ":80";
+		}
+	}
+
+	conn, err := net.Dial(t.Network, t.LocalAddr, addr);
+	if err != nil {
+		return nil, err;
+	}
+	if u.Scheme == "https" {
+		var config tls.Config;
+		if t.TLSConfig != nil {
+			config = *t.TLSConfig;
+		}
+		config.ServerName = strings.Split(host, ":", 2)[0];
+		var tlsConn = tls.Client(conn, &config);
+		if err = tlsConn.Handshake(); err != nil {
+			conn.Close();
+			return nil, err;
+		}
+		conn = tlsConn;
+	}
+
+	// The request is written by hand, it's only ever these few headers
+	//
+	var w = bufio.NewWriter(conn);
+	fmt.Fprintf(w, "%s %s HTTP/1.1\r\n", method, requestURI(rawurl));
+	fmt.Fprintf(w, "Host: %s\r\n", host);
+	fmt.Fprintf(w, "User-Agent: %s\r\n", t.UserAgent);
+	fmt.Fprintf(w, "Connection: close\r\n");
+	if t.Compressed {
+		fmt.Fprintf(w, "Accept-Encoding: gzip, deflate\r\n");
+	}
+	for name, value := range header {
+		fmt.Fprintf(w, "%s: %s\r\n", name, value);
+	}
+	if method == "POST" {
+		fmt.Fprintf(w, "Content-Length: %d\r\n", len(body));
+	}
+	fmt.Fprintf(w, "\r\n%s", body);
+	if err = w.Flush(); err != nil {
+		conn.Close();
+		return nil, err;
+	}
+
+	res, err := http.ReadResponse(bufio.NewReader(conn), method);
+	if err != nil {
+		conn.Close();
+		return nil, err;
+	}
+
+	// The length of a compressed body is not the length of the document.
+	// Responses to HEAD have no body to decode.
+	//
+	var reader io.Reader = res.Body;
+	var encoding = strings.ToLower(res.GetHeader("Content-Encoding"));
+	if method == "HEAD" {
+		encoding = "";
+	}
+	switch encoding {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(res.Body);
+		res.ContentLength = -1;
+	case "deflate":
+		reader, err = zlib.NewReader(res.Body);
+		res.ContentLength = -1;
+	}
+	if err != nil {
+		conn.Close();
+		return nil, err;
+	}
+	res.Body = &connBody{ reader, conn };
+	return res, nil;
+}
+
+// Returns the path and query of a URL as written in the request line,
+// the fragment is never sent
+//
+func requestURI(rawurl string) string {
+	var rest = rawurl;
+	if i := strings.Index(rest, "#"); i >= 0 {
+		rest = rest[0:i];
+	}
+	if i := strings.Index(rest, "://"); i >= 0 {
+		rest = rest[i + 3:];
+	}
+	for i := 0; i < len(rest); i++ {
+		switch rest[i] {
+		case '/':
+			return rest[i:];
+		case '?':
+			return "/" + rest[i:];
+		}
+	}
+	return "/";
+}
+
+// Resolves the Location of a redirect, or any other link, against the
+// URL it came from
+//
+func ResolveLocation(rawurl string, location string) string {
+	if strings.Index(location, "://") >= 0 {
+		return location;
+	}
+
+	// The query and fragment may have slashes too, but they aren't part
+	// of the path
+	//
+	for _, mark := range []string { "#", "?" } {
+		if i := strings.Index(rawurl, mark); i >= 0 {
+			rawurl = rawurl[0:i];
+		}
+	}
+
+	// A URL without a path ("http://host") is all base
+	//
+	var base = rawurl;
+	var start = 0;
+	if i := strings.Index(rawurl, "://"); i >= 0 {
+		start = i + 3;
+	}
+	if i := strings.Index(rawurl[start:], "/"); i >= 0 {
+		base = rawurl[0:start + i];
+	}
+
+	if strings.HasPrefix(location, "/") {
+		return base + location;
+	}
+	var uri = requestURI(rawurl);
+	return base + uri[0:strings.LastIndex(uri, "/") + 1] + location;
+}
@@ -0,0 +1,56 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Tests of the HTTP transport.
+*/
+
+package client
+
+import (
+	"testing"
+)
+
+var locationTests = []struct {
+	rawurl, location, out string;
+} {
+	{ "http://host", "/lunch", "http://host/lunch" },
+	{ "http://host", "lunch", "http://host/lunch" },
+	{ "http://host/", "/lunch", "http://host/lunch" },
+	{ "http://host:8080/a/b?c", "/lunch", "http://host:8080/lunch" },
+	{ "http://host/a/b", "c", "http://host/a/c" },
+	{ "http://host/a", "https://other/x", "https://other/x" },
+	{ "http://host/a?x=/b", "c", "http://host/c" },
+	{ "http://host?x=/b", "c", "http://host/c" },
+	{ "http://host?x=/b", "/lunch", "http://host/lunch" },
+	{ "http://host/a/b#x/y", "c", "http://host/a/c" },
+	{ "http://host/a/b?x#y/z", "c", "http://host/a/c" },
+};
+
+var requestURITests = []struct {
+	rawurl, uri string;
+} {
+	{ "http://host", "/" },
+	{ "http://host/a/b?c=d", "/a/b?c=d" },
+	{ "http://host?x=/b", "/?x=/b" },
+	{ "http://host/a#x/y", "/a" },
+};
+
+func TestRequestURI(t *testing.T) {
+	for _, test := range requestURITests {
+		if uri := requestURI(test.rawurl); uri != test.uri {
+			t.Errorf("requestURI(%q) = %q, want %q", test.rawurl, uri, test.uri);
+		}
+	}
+}
+
+func TestResolveLocation(t *testing.T) {
+	for _, test := range locationTests {
+		var out = ResolveLocation(test.rawurl, test.location);
+		if out != test.out {
+			t.Errorf("ResolveLocation(%q, %q) = %q, want %q", test.rawurl, test.location, out, test.out);
+		}
+	}
+}
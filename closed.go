@@ -0,0 +1,49 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Detection of closed restaurants ("Stängt", "Semesterstängt"), so they
+    can be told apart from restaurants whose menu couldn't be parsed.
+*/
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Closure texts, matched against the lowercased text with its HTML
+// entities decoded
+//
+var rx_closed = regexp.MustCompile("stängt|closed|ingen lunch|ingen servering");
+
+// Most lines a menu may have and still be only a closure note, longer
+// menus that mention a closure ("stängt lördagar") are real menus
+//
+const closedMaxLines = 2;
+
+// Returns true and the closure note if the restaurant is closed for the
+// day. Only the menu is considered, unless it's empty, since the
+// description often has the regular closing days.
+//
+func DetectClosed(menu string, description string) (bool, string) {
+	var text = strings.TrimSpace(menu);
+	if text == "" {
+		text = strings.TrimSpace(description);
+	}
+
+	var lines = strings.Split(text, "\n", -1);
+	if text == "" || len(lines) > closedMaxLines {
+		return false, "";
+	}
+
+	for i := 0; i < len(lines); i++ {
+		if rx_closed.MatchString(strings.ToLower(DecodeEntities(lines[i]))) {
+			return true, strings.TrimSpace(strings.Replace(text, "\n", " ", -1));
+		}
+	}
+	return false, "";
+}
@@ -0,0 +1,169 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Shell completion scripts for bash, zsh and fish ("completion
+    <shell>"), with the commands, the flags, the values of the flags with
+    a fixed set of values, and the cities and week numbers found in the
+    archive given with -archive.
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Values of the flags that only take a fixed set of values
+//
+var flagValues = map[string][]string {
+	"order":	[]string { "source", "alpha", "favorites", "distance" },
+	"lang":		[]string { "sv", "en" },
+	"holidays":	[]string { "mark", "skip", "ignore" },
+	"check-images":	[]string { "flag", "drop", "placeholder" },
+	"text":		[]string { "raw", "plain", "html", "markdown" },
+	"robots":	[]string { "fetch", "ignore" },
+	"ip":		[]string { "4", "6", "any" },
+	"genmodels":	[]string { "kotlin", "java", "go" },
+};
+
+// The commands given after the flags and the shells of "completion"
+//
+var subcommands = []string { "approve", "backfill", "completion", "parse", "probe", "runs", "verify" };
+var shells = []string { "bash", "zsh", "fish" };
+
+// Returns the completion script for the shell
+//
+func Completion(shell string, archiveDir string) (string, os.Error) {
+	var values = make(map[string][]string);
+	for name, list := range flagValues {
+		values[name] = list;
+	}
+	if archiveDir != "" {
+		values["city"], values["week"] = archivedCities(archiveDir);
+	}
+
+	var buf = bytes.NewBuffer(make([]byte, 0));
+	switch shell {
+	case "bash", "zsh":
+		if shell == "zsh" {
+			fmt.Fprintf(buf, "autoload -U +X bashcompinit && bashcompinit\n");
+		}
+		bashCompletion(buf, values);
+	case "fish":
+		fishCompletion(buf, values);
+	default:
+		return "", os.NewError("unknown shell " + shell);
+	}
+	return buf.String(), nil;
+}
+
+// Writes the completion function for bash, also used by zsh through
+// its bash compatibility
+//
+func bashCompletion(buf *bytes.Buffer, values map[string][]string) {
+	var flags = make([]string, 0);
+	flag.VisitAll(func(f *flag.Flag) {
+		if isBoolFlag(f) {
+			flags = append(flags, "-" + f.Name);
+		} else {
+			flags = append(flags, "-" + f.Name + "=");
+		}
+	});
+
+	fmt.Fprintf(buf, "_lunchguiden() {\n");
+	fmt.Fprintf(buf, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n");
+	fmt.Fprintf(buf, "\tcase \"${COMP_WORDS[COMP_CWORD-1]}\" in\n");
+	fmt.Fprintf(buf, "\tcompletion)\n");
+	fmt.Fprintf(buf, "\t\tCOMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") ); return ;;\n", strings.Join(shells, " "));
+	fmt.Fprintf(buf, "\tapprove|backfill|parse|verify)\n");
+	fmt.Fprintf(buf, "\t\treturn ;;\n");
+	fmt.Fprintf(buf, "\tesac\n");
+	fmt.Fprintf(buf, "\tcase \"$cur\" in\n");
+	for _, name := range sortedKeys(values) {
+		fmt.Fprintf(buf, "\t-%s=*)\n", name);
+		fmt.Fprintf(buf, "\t\tCOMPREPLY=( $(compgen -P \"-%s=\" -W \"%s\" -- \"${cur#-%s=}\") ) ;;\n", name, strings.Join(values[name], " "), name);
+	}
+	fmt.Fprintf(buf, "\t-*)\n");
+	fmt.Fprintf(buf, "\t\tCOMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") ) ;;\n", strings.Join(flags, " "));
+	fmt.Fprintf(buf, "\t*)\n");
+	fmt.Fprintf(buf, "\t\tCOMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") ) ;;\n", strings.Join(subcommands, " "));
+	fmt.Fprintf(buf, "\tesac\n");
+	fmt.Fprintf(buf, "}\n");
+	fmt.Fprintf(buf, "complete -o nospace -o default -F _lunchguiden lunchguiden\n");
+}
+
+// Writes the completions for fish
+//
+func fishCompletion(buf *bytes.Buffer, values map[string][]string) {
+	fmt.Fprintf(buf, "complete -c lunchguiden -f -n '__fish_use_subcommand' -a '%s'\n", strings.Join(subcommands, " "));
+	fmt.Fprintf(buf, "complete -c lunchguiden -f -n '__fish_seen_subcommand_from completion' -a '%s'\n", strings.Join(shells, " "));
+	flag.VisitAll(func(f *flag.Flag) {
+		var usage = strings.Replace(f.Usage, "'", "\\'", -1);
+		switch {
+		case isBoolFlag(f):
+			fmt.Fprintf(buf, "complete -c lunchguiden -o %s -d '%s'\n", f.Name, usage);
+		case len(values[f.Name]) > 0:
+			fmt.Fprintf(buf, "complete -c lunchguiden -o %s -x -a '%s' -d '%s'\n", f.Name, strings.Join(values[f.Name], " "), usage);
+		default:
+			fmt.Fprintf(buf, "complete -c lunchguiden -o %s -r -d '%s'\n", f.Name, usage);
+		}
+	});
+}
+
+// Tells if the flag is a boolean flag, which takes no value
+//
+func isBoolFlag(f *flag.Flag) bool {
+	return f.DefValue == "true" || f.DefValue == "false";
+}
+
+// Returns the cities and the week numbers in the archive
+//
+func archivedCities(dir string) ([]string, []string) {
+	var cities = make([]string, 0);
+	var weeks = make(map[int]bool);
+
+	dirs, _ := ioutil.ReadDir(dir + "/json");
+	for i := 0; i < len(dirs); i++ {
+		archived, err := ReadArchivedWeeks(dir, dirs[i].Name);
+		if err != nil {
+			continue;
+		}
+		var city = dirs[i].Name;
+		for _, data := range archived {
+			weeks[data.Week] = true;
+			if strings.Index(data.City, " ") < 0 {
+				city = data.City;
+			}
+		}
+		cities = append(cities, city);
+	}
+
+	var list = make([]string, 0, len(weeks));
+	for week := 1; week <= 53; week++ {
+		if weeks[week] {
+			list = append(list, fmt.Sprint(week));
+		}
+	}
+	sort.SortStrings(cities);
+	return cities, list;
+}
+
+// Returns the keys of the map in order
+//
+func sortedKeys(m map[string][]string) []string {
+	var keys = make([]string, 0, len(m));
+	for key, _ := range m {
+		keys = append(keys, key);
+	}
+	sort.SortStrings(keys);
+	return keys;
+}
@@ -0,0 +1,70 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Scoring of how trustworthy the parse of a day is, from 0 (certainly
+    broken) to 1 (looks like a normal day).
+*/
+
+package main
+
+import (
+	"math"
+)
+
+// Menus shorter than this on average are suspicious
+//
+const normalMenuLength = 40;
+
+// Scores the parsed restaurants of a day from the number of restaurants
+// compared to the historical average for the weekday, the fraction of
+// restaurants with a known logo and the average length of the menus
+//
+func Confidence(restaurants []RestData, average float64) float64 {
+	if len(restaurants) == 0 {
+		return 0;
+	}
+
+	var count = float64(len(restaurants));
+	var known, length = 0.0, 0.0;
+	for i := 0; i < len(restaurants); i++ {
+		if restaurants[i].Name != "" {
+			known++;
+		}
+		length += float64(len(restaurants[i].Menu));
+	}
+
+	var countScore = 1.0;
+	if average > 0 {
+		countScore = math.Fmin(1, count / average);
+	}
+	var knownScore = known / count;
+	var menuScore = math.Fmin(1, length / count / normalMenuLength);
+
+	var score = 0.4 * countScore + 0.3 * knownScore + 0.3 * menuScore;
+	return math.Floor(score * 100 + 0.5) / 100;
+}
+
+// Returns the average number of restaurants on a weekday in the archived
+// weeks, leaving out the current week (by its WeekKey) and days without
+// restaurants
+//
+func HistoricalAverage(weeks map[int]*DataStruct, current int, day int) float64 {
+	var total, n = 0, 0;
+
+	for key, data := range weeks {
+		var count = len(data.Days[day].Restaurants) + len(data.Days[day].Specials);
+		if key == current || count == 0 {
+			continue;
+		}
+		total += count;
+		n++;
+	}
+
+	if n == 0 {
+		return 0;
+	}
+	return float64(total) / float64(n);
+}
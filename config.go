@@ -0,0 +1,205 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    The optional configuration file given with -config. It's a JSON
+    document where every section is optional, for example:
+
+    {
+        "Rules": [
+            { "Category": "soup", "Pattern": "soppa" }
+        ],
+        "Nutrition": [
+            { "Category": "fried", "Pattern": "friter|panerad" }
+        ],
+        "Providers": {
+            "mirror": {
+                "Method": "POST",
+                "Url": "{url}",
+                "Body": "vecka={week}&veckodag={day}",
+                "Charset": "iso-8859-1",
+                "Days": [ "Måndag", "Tisdag", "Onsdag", "Torsdag", "Fredag" ],
+                "ImageBase": "https://mirror.example.com/lunch/"
+            }
+        },
+        "ImageHosts": [ "service.dt.se", "mirror.example.com" ],
+        "Hooks": {
+            "post_parse": [ "/usr/local/bin/add-canteen", "--city", "falun" ]
+        },
+        "Aliases": {
+            "gamla-staberg-2011": "gamla-staberg"
+        },
+        "Features": {
+            "tokenizer_parser": false
+        },
+        "Restaurants": {
+            "z-krog": { "Name": "Z-krog & Bar", "Tags": [ "dagens" ], "Lat": 60.6065, "Lon": 15.6355 },
+            "subway": { "Hidden": true }
+        }
+    }
+*/
+
+package main
+
+import (
+	"os"
+	"io/ioutil"
+	"json"
+	"regexp"
+)
+
+type Config struct {
+	Rules []Rule;
+	Nutrition []Rule;
+	Providers map[string]Provider;
+	Restaurants map[string]Override;
+	Aliases map[string]string;	// Restaurant ids and the ids they are the same as
+	ImageHosts []string;		// Hosts the logos may be on
+	Features map[string]bool;
+	Hooks map[string][]string;
+}
+
+// Rule for classifying dishes, a dish is given the category when the
+// regular expression matches its lowercased text
+//
+type Rule struct {
+	Category string;
+	Pattern string;
+	rx *regexp.Regexp;
+}
+
+// Corrections of a restaurant, by id, applied after parsing. Empty
+// fields leave the parsed values as they are.
+//
+type Override struct {
+	Name string;
+	ImageUrl string;
+	Tags []string;			// Categories added to every dish
+	Hidden bool;			// Left out of the output
+	Lat float64;			// Position, for the distances with -office
+	Lon float64;
+}
+
+// Behaviors that can be turned off in the configuration, so a new
+// behavior can be rolled back without going back to an older build.
+// All features are on unless turned off.
+//
+var features = []string {
+	"tokenizer_parser",		// The tokenizer strategy of the parser
+	"heuristic_parser",		// The heuristic strategy of the parser
+	"dish_splitting",		// Dishes split from the menus
+	"closed_detection",		// Closed restaurants flagged
+	"serving_hours",		// Serving hours from the descriptions
+	"contact_extraction",		// Phone numbers and web addresses from the descriptions
+	"weekly_menus",			// Menus that are the same all week flagged
+	"compressed_downloads",		// Compressed responses asked for and decoded
+};
+
+// Tells if the feature is turned on in the configuration in use
+//
+func Enabled(feature string) bool {
+	on, found := config.Features[feature];
+	return !found || on;
+}
+
+// The configuration in use, replaced if a file is given with -config
+//
+var config = DefaultConfig();
+
+// Returns the configuration used when no file is given, or for the
+// sections missing in the file
+//
+func DefaultConfig() *Config {
+	var c = new(Config);
+	c.Rules = []Rule {
+		Rule{ Category: "soup",		Pattern: "soppa|buljong|consomm" },
+		Rule{ Category: "salad",	Pattern: "sallad" },
+		Rule{ Category: "vegetarian",	Pattern: "vegetari|vegan|halloumi|falafel|grönsaksbiff|bönbiff|linsgryta|quorn|tofu" },
+		Rule{ Category: "fish",		Pattern: "fisk|lax|torsk|sej|kolja|rödspätta|sill|strömming|räk|tonfisk|pangasius|gös|abborre|skaldjur" },
+		Rule{ Category: "meat",		Pattern: "kött|fläsk|biff|kyckling|korv|lamm|kalv|oxfil|bacon|skinka|schnitzel|entrecote|högrev|kassler|pytt" },
+		Rule{ Category: "dagens",	Pattern: "dagens" },
+	};
+	c.Nutrition = []Rule {
+		Rule{ Category: "fried",	Pattern: "friter|frityr|panerad|panerat|schnitzel|pommes|krispig" },
+		Rule{ Category: "creamy",	Pattern: "gräddig|grädd|crème|creme|ostsås|carbonara|bearnaise|hollandaise" },
+		Rule{ Category: "fish",		Pattern: "fisk|lax|torsk|sej|kolja|rödspätta|sill|strömming|tonfisk|pangasius|gös|abborre" },
+		Rule{ Category: "whole-grain",	Pattern: "fullkorn|råg|havre|matvete|quinoa|bulgur|dinkel|råris" },
+	};
+	c.Providers = map[string]Provider {
+		"lunchguiden": defaultProvider,
+	};
+	c.ImageHosts = []string{ "service.dt.se" };
+	return c;
+}
+
+// Reads the configuration file and fills in defaults for the missing
+// sections. The regular expressions are compiled so that errors in
+// them are reported at startup.
+//
+func LoadConfig(path string) (*Config, os.Error) {
+	var c = new(Config);
+
+	data, err := ioutil.ReadFile(path);
+	if err != nil {
+		return nil, err;
+	}
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, err;
+	}
+
+	var defaults = DefaultConfig();
+	if len(c.Rules) == 0 {
+		c.Rules = defaults.Rules;
+	}
+	if len(c.Nutrition) == 0 {
+		c.Nutrition = defaults.Nutrition;
+	}
+	if len(c.ImageHosts) == 0 {
+		c.ImageHosts = defaults.ImageHosts;
+	}
+	if c.Providers == nil {
+		c.Providers = make(map[string]Provider);
+	}
+	if _, found := c.Providers["lunchguiden"]; !found {
+		c.Providers["lunchguiden"] = defaultProvider;
+	}
+
+	for name, provider := range c.Providers {
+		if len(provider.Days) != 0 && len(provider.Days) != 5 {
+			return nil, os.NewError("provider " + name + " must have exactly five days");
+		}
+	}
+	for name, _ := range c.Hooks {
+		if !contains(hooks, name) {
+			return nil, os.NewError("unknown hook " + name);
+		}
+	}
+	for name, _ := range c.Features {
+		if !contains(features, name) {
+			return nil, os.NewError("unknown feature " + name);
+		}
+	}
+
+	if err = compileRules(c.Rules); err != nil {
+		return nil, err;
+	}
+	if err = compileRules(c.Nutrition); err != nil {
+		return nil, err;
+	}
+	return c, nil;
+}
+
+// Compiles the regular expressions of the rules
+//
+func compileRules(rules []Rule) os.Error {
+	var err os.Error;
+	for i := 0; i < len(rules); i++ {
+		rules[i].rx, err = regexp.Compile(rules[i].Pattern);
+		if err != nil {
+			return os.NewError("invalid pattern for " + rules[i].Category + ": " + err.String());
+		}
+	}
+	return nil;
+}
@@ -0,0 +1,67 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Extraction of phone numbers and web addresses from the description of
+    a restaurant, for the call and visit buttons of the app.
+*/
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Swedish phone numbers in the usual notations: 023-123 45,
+// 0243-22 33 44, 070-123 45 67 or +46 23 12345
+//
+var rx_phone = regexp.MustCompile("(\\+46|0)[ ]?[1-9][0-9]*([\\-/ ]+[0-9]+)*");
+
+// Web addresses, with or without the scheme
+//
+var rx_website = regexp.MustCompile("(https?://|www\\.)[a-zA-Z0-9.\\-]+\\.[a-zA-Z]+(/[^ \t\n<\"]*)?");
+
+// Returns the first phone number in the text in E.164 format, like
+// +4623123456, or an empty string if there is none
+//
+func ExtractPhone(text string) string {
+	var candidates = rx_phone.FindAllString(DecodeEntities(text), -1);
+
+	for i := 0; i < len(candidates); i++ {
+		var digits = make([]byte, 0, len(candidates[i]));
+		for j := 0; j < len(candidates[i]); j++ {
+			if c := candidates[i][j]; c >= '0' && c <= '9' {
+				digits = append(digits, c);
+			}
+		}
+
+		// National numbers without the leading zero are 7 to 9 digits
+		//
+		var national = string(digits);
+		if strings.HasPrefix(candidates[i], "+46") {
+			national = national[2:];
+		} else {
+			national = national[1:];
+		}
+		if len(national) >= 7 && len(national) <= 9 {
+			return "+46" + national;
+		}
+	}
+	return "";
+}
+
+// Returns the first web address in the text, always with a scheme, or an
+// empty string if there is none
+//
+func ExtractWebsite(text string) string {
+	var website = rx_website.FindString(text);
+	website = strings.TrimRight(website, ".,;:)");
+
+	if website != "" && !strings.HasPrefix(website, "http") {
+		website = "http://" + website;
+	}
+	return website;
+}
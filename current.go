@@ -0,0 +1,61 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    The current week, a symlink (or a copy where symlinks can't be made)
+    to the last published week, so clients don't need to work out the
+    week number themselves.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+)
+
+// Points the current file and its md5 file at the published output. The
+// link is made next to the new one and renamed over the old one, so the
+// current file never goes missing.
+//
+func LinkCurrent(current string, out string) os.Error {
+	if err := os.MkdirAll(path.Dir(current), 0755); err != nil {
+		return err;
+	}
+	for _, suffix := range []string{ "", ".md5" } {
+		var target = out + suffix;
+		if path.Dir(current) == path.Dir(out) {
+			target = path.Base(target);
+		} else if !path.IsAbs(target) {
+			wd, err := os.Getwd();
+			if err != nil {
+				return err;
+			}
+			target = path.Join(wd, target);
+		}
+
+		var tmp = current + suffix + ".tmp";
+		os.Remove(tmp);
+		if err := os.Symlink(target, tmp); err != nil {
+			fmt.Printf("Unable to link %s, copying instead: %s\n", current + suffix, err);
+			if err = copyFile(out + suffix, tmp); err != nil {
+				return err;
+			}
+		}
+		if err := os.Rename(tmp, current + suffix); err != nil {
+			return err;
+		}
+	}
+	return nil;
+}
+
+func copyFile(from string, to string) os.Error {
+	data, err := ReadInput(from);
+	if err != nil {
+		return err;
+	}
+	return WriteFile(to, data);
+}
@@ -0,0 +1,109 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Debug output of the HTML fragment of every restaurant together with
+    the result of parsing it, for finding out why a field came out empty.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"json"
+	"os"
+	"strings"
+)
+
+// Writes the HTML fragment of each restaurant of a day to the directory,
+// followed by the parsed result as JSON in a file with the same name.
+// The fragments are parsed with the strategy that parses the whole
+// document, recovering like Parse does, and a failure is written in
+// place of the result. The fragment is written first so it's available
+// even if parsing it fails.
+//
+func DumpFragments(dir string, city string, week int, day int, in []byte) os.Error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err;
+	}
+
+	var _, name = Parse(in);
+	var strategy = strategyNamed(name);
+
+	var fragments = SplitRestaurants(string(in));
+	if len(fragments) == 0 {
+		fragments = splitAtLogos(string(in));
+	}
+	for i := 0; i < len(fragments); i++ {
+		var base = fmt.Sprintf("%s/%s-v%d-d%d-%02d", dir, Slug(city), week, day, i);
+
+		if err := ioutil.WriteFile(base + ".html", []byte(fragments[i]), 0644); err != nil {
+			return err;
+		}
+
+		parsed, err := tryStrategy(strategy, fragments[i]);
+		if err != nil {
+			var text = fmt.Sprintf("%s parser failed: %s\n", strategy.Name, err);
+			if err = ioutil.WriteFile(base + ".error", []byte(text), 0644); err != nil {
+				return err;
+			}
+			continue;
+		}
+
+		var output = bytes.NewBuffer(make([]byte, 0));
+		jsonOutput, err := json.Marshal(parsed);
+		if err != nil {
+			return err;
+		}
+		json.Indent(output, jsonOutput, "", "\t");
+
+		if err = ioutil.WriteFile(base + ".json", output.Bytes(), 0644); err != nil {
+			return err;
+		}
+	}
+	return nil;
+}
+
+// Returns the parser strategy with the name, the legacy one if there is
+// no such strategy, like when no strategy parsed the document
+//
+func strategyNamed(name string) Strategy {
+	var legacy Strategy;
+	for i := 0; i < len(strategies); i++ {
+		if strategies[i].Name == name {
+			return strategies[i];
+		}
+		if strategies[i].Name == "legacy" {
+			legacy = strategies[i];
+		}
+	}
+	return legacy;
+}
+
+// Splits the document at the image tags of the logos, for documents the
+// legacy parser can't split
+//
+func splitAtLogos(in string) []string {
+	var logos = rx_logo.FindAllStringIndex(in, -1);
+	var fragments = make([]string, 0, len(logos));
+	var start = -1;
+
+	for i := 0; i < len(logos); i++ {
+		var lt = strings.LastIndex(in[0:logos[i][0]], "<");
+		if lt <= start {
+			continue;
+		}
+		if start >= 0 {
+			fragments = append(fragments, in[start:lt]);
+		}
+		start = lt;
+	}
+	if start >= 0 {
+		fragments = append(fragments, in[start:]);
+	}
+	return fragments;
+}
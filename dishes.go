@@ -0,0 +1,98 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Splitting of the menu text into separate dishes and rule based
+    classification of them (soup, salad, vegetarian, fish, meat, dagens),
+    optionally with rough nutrition hints (-nutrition).
+*/
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Splits the menu of a restaurant into dishes, one per line, and
+// classifies each of them
+//
+func SplitDishes(menu string) []DishData {
+	var lines = strings.Split(menu, "\n", -1);
+	var dishes = make([]DishData, 0, len(lines));
+
+	for i := 0; i < len(lines); i++ {
+		var text = strings.TrimSpace(lines[i]);
+
+		// The list markers are added by the parser for <LI> tags
+		//
+		if strings.HasPrefix(text, "* ") {
+			text = strings.TrimSpace(text[2:]);
+		}
+		if text == "" {
+			continue;
+		}
+
+		var dish DishData;
+		dish.Text = text;
+		dish.Categories = ClassifyDish(text);
+		if *nutrition {
+			dish.Nutrition = NutritionHints(text);
+		}
+
+		// Prices are given in kronor on Lunchguiden
+		//
+		dish.Price, dish.PriceOre = ExtractPrice(text);
+		if dish.Price != "" {
+			dish.Currency = "SEK";
+		}
+		dishes = append(dishes, dish);
+	}
+	return dishes;
+}
+
+// Returns the categories of all rules in the configuration that match
+// the text of the dish
+//
+func ClassifyDish(text string) []string {
+	return matchRules(config.Rules, text);
+}
+
+// Returns the rough nutrition hints (fried, creamy, whole-grain etc) of
+// the nutrition rules in the configuration that match the text of the
+// dish
+//
+func NutritionHints(text string) []string {
+	return matchRules(config.Nutrition, text);
+}
+
+// Returns the categories of the rules matching the text
+//
+func matchRules(rules []Rule, text string) []string {
+	var categories = make([]string, 0);
+	text = strings.ToLower(DecodeEntities(text));
+
+	for i := 0; i < len(rules); i++ {
+		var rule = &rules[i];
+		if rule.rx == nil {
+			rule.rx = regexp.MustCompile(rule.Pattern);
+		}
+		if rule.rx.MatchString(text) && !contains(categories, rule.Category) {
+			categories = append(categories, rule.Category);
+		}
+	}
+	return categories;
+}
+
+// Checks if the string slice contains the value
+//
+func contains(list []string, value string) bool {
+	for i := 0; i < len(list); i++ {
+		if list[i] == value {
+			return true;
+		}
+	}
+	return false;
+}
@@ -0,0 +1,144 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Walking distances from the office to the restaurants (-office), as
+    the crow flies or along the streets from an OSRM server (-osrm), so
+    the app can filter on "within 10 minutes" offline. The positions of
+    the restaurants are given in the configuration.
+*/
+
+package main
+
+import (
+	"fmt"
+	"http"
+	"io/ioutil"
+	"json"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Location struct {
+	Lat float64;
+	Lon float64;
+}
+
+// Walking speed in meters per minute and the detour of real streets
+// compared to a straight line, used without an OSRM server
+//
+const walkSpeed = 80;
+const detourFactor = 1.3;
+
+// Radius of the earth in meters
+//
+const earthRadius = 6371000;
+
+// Parses a location given as lat,lon
+//
+func ParseLocation(s string) (*Location, os.Error) {
+	var coords = strings.Split(s, ",", 2);
+	if len(coords) != 2 {
+		return nil, os.NewError("location must be given as lat,lon");
+	}
+	lat, err := strconv.Atof64(strings.TrimSpace(coords[0]));
+	if err != nil || lat < -90 || lat > 90 {
+		return nil, os.NewError("invalid latitude " + coords[0]);
+	}
+	lon, err := strconv.Atof64(strings.TrimSpace(coords[1]));
+	if err != nil || lon < -180 || lon > 180 {
+		return nil, os.NewError("invalid longitude " + coords[1]);
+	}
+	return &Location{ lat, lon }, nil;
+}
+
+// Location of the office given with -office, nil for no distances
+//
+var office *Location;
+
+// Distances already looked up, by restaurant id, so every restaurant is
+// only routed once per run
+//
+var distances = make(map[string][]int);
+
+// Sets the walking distance and time from the office for the restaurants
+// with a position in the configuration
+//
+func AddDistances(list []RestData, office *Location, osrm string) {
+	for i := 0; i < len(list); i++ {
+		var r = &list[i];
+		override, found := config.Restaurants[r.Id];
+		if !found || (override.Lat == 0 && override.Lon == 0) {
+			continue;
+		}
+
+		if cached, found := distances[r.Id]; found {
+			r.DistanceMeters, r.WalkMinutes = cached[0], cached[1];
+			continue;
+		}
+
+		var to = &Location{ override.Lat, override.Lon };
+		var meters = int(Haversine(office, to) * detourFactor + 0.5);
+		var minutes = (meters + walkSpeed - 1) / walkSpeed;
+		if osrm != "" {
+			m, s, err := Route(osrm, office, to);
+			if err != nil {
+				log.Printf("WARNING: No route to %s, using the straight line: %s\n", r.Id, err);
+			} else {
+				meters, minutes = m, (s + 59) / 60;
+			}
+		}
+
+		distances[r.Id] = []int{ meters, minutes };
+		r.DistanceMeters, r.WalkMinutes = meters, minutes;
+	}
+}
+
+// Returns the distance in meters between two locations as the crow flies
+//
+func Haversine(a *Location, b *Location) float64 {
+	var rad = math.Pi / 180;
+	var dLat = (b.Lat - a.Lat) * rad;
+	var dLon = (b.Lon - a.Lon) * rad;
+
+	var h = math.Sin(dLat / 2) * math.Sin(dLat / 2) +
+		math.Cos(a.Lat * rad) * math.Cos(b.Lat * rad) * math.Sin(dLon / 2) * math.Sin(dLon / 2);
+	return 2 * earthRadius * math.Asin(math.Sqrt(h));
+}
+
+// Returns the walking distance in meters and time in seconds between two
+// locations from the route service of an OSRM server
+//
+func Route(server string, from *Location, to *Location) (int, int, os.Error) {
+	var url = fmt.Sprintf("%s/route/v1/foot/%f,%f;%f,%f?overview=false", strings.TrimRight(server, "/"), from.Lon, from.Lat, to.Lon, to.Lat);
+
+	res, err := Do(&Request{ Method: "GET", Url: url });
+	if err != nil {
+		return 0, 0, err;
+	}
+	defer res.Body.Close();
+	if res.StatusCode != http.StatusOK {
+		return 0, 0, &StatusError{ url, res.Status };
+	}
+	body, err := ioutil.ReadAll(res.Body);
+	if err != nil {
+		return 0, 0, err;
+	}
+
+	var route interface{};
+	if err = json.Unmarshal(body, &route); err != nil {
+		return 0, 0, err;
+	}
+	var routes, _ = lookup(route, "routes").([]interface{});
+	if len(routes) == 0 {
+		return 0, 0, os.NewError("no route found");
+	}
+	var meters, _ = lookup(routes[0], "distance").(float64);
+	var seconds, _ = lookup(routes[0], "duration").(float64);
+	return int(meters + 0.5), int(seconds + 0.5), nil;
+}
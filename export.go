@@ -0,0 +1,179 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Export of the whole archive to a gzipped tarball and import of such a
+    tarball into another archive directory (-export, -import), for backups
+    and moving the archive to another host. An export named .tar isn't
+    compressed, so it can be mirrored in pieces (see chunks.go).
+*/
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// Writes all files in the archive directory to a tarball, with names
+// relative to the archive directory. The tarball is gzipped unless the
+// name ends with .tar.
+//
+func ExportArchive(dir string, file string) os.Error {
+	f, err := os.Open(file, os.O_WRONLY | os.O_CREAT | os.O_TRUNC, 0644);
+	if err != nil {
+		return err;
+	}
+	defer f.Close();
+
+	var zw io.WriteCloser;
+	var tw = tar.NewWriter(f);
+	if !strings.HasSuffix(file, ".tar") {
+		if zw, err = gzip.NewWriter(f); err != nil {
+			return err;
+		}
+		tw = tar.NewWriter(zw);
+	}
+
+	count, err := exportDir(tw, dir, "");
+	if err != nil {
+		return err;
+	}
+	if err = tw.Close(); err != nil {
+		return err;
+	}
+	if zw != nil {
+		if err = zw.Close(); err != nil {
+			return err;
+		}
+	}
+	fmt.Printf("Exported %d files to %s\n", count, file);
+	return nil;
+}
+
+// Adds the files of a directory and its subdirectories to the tarball
+//
+func exportDir(tw *tar.Writer, dir string, name string) (int, os.Error) {
+	files, err := ioutil.ReadDir(path.Join(dir, name));
+	if err != nil {
+		return 0, err;
+	}
+
+	var count = 0;
+	for i := 0; i < len(files); i++ {
+		var entry = path.Join(name, files[i].Name);
+
+		if files[i].IsDirectory() {
+			n, err := exportDir(tw, dir, entry);
+			if err != nil {
+				return count, err;
+			}
+			count += n;
+			continue;
+		}
+		if !files[i].IsRegular() {
+			continue;
+		}
+
+		data, err := ioutil.ReadFile(path.Join(dir, entry));
+		if err != nil {
+			return count, err;
+		}
+		var header = &tar.Header{
+			Name:		entry,
+			Mode:		0644,
+			Size:		int64(len(data)),
+			Mtime:		files[i].Mtime_ns / 1e9,
+			Typeflag:	tar.TypeReg,
+		};
+		if err = tw.WriteHeader(header); err != nil {
+			return count, err;
+		}
+		if _, err = tw.Write(data); err != nil {
+			return count, err;
+		}
+		count++;
+	}
+	return count, nil;
+}
+
+// Unpacks a tarball made by ExportArchive into the archive directory,
+// or the tarball put together from the index of its pieces (.chunks).
+// Files that already exist are kept as they are, so importing into an
+// archive in use never loses data.
+//
+func ImportArchive(dir string, file string) os.Error {
+	if strings.HasSuffix(file, ".chunks") {
+		var err os.Error;
+		if file, err = AssembleChunks(file); err != nil {
+			return err;
+		}
+	}
+
+	f, err := os.Open(file, os.O_RDONLY, 0);
+	if err != nil {
+		return err;
+	}
+	defer f.Close();
+
+	var tr = tar.NewReader(f);
+	if !strings.HasSuffix(file, ".tar") {
+		zr, err := gzip.NewReader(f);
+		if err != nil {
+			return err;
+		}
+		defer zr.Close();
+		tr = tar.NewReader(zr);
+	}
+
+	var imported, kept = 0, 0;
+	for {
+		header, err := tr.Next();
+		if err == os.EOF || header == nil {
+			break;
+		}
+		if err != nil {
+			return err;
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue;
+		}
+
+		// Never write outside of the archive directory
+		//
+		var name = path.Clean(header.Name);
+		if strings.HasPrefix(name, "/") || name == ".." || strings.HasPrefix(name, "../") {
+			return os.NewError("invalid file name in tarball: " + header.Name);
+		}
+
+		var target = path.Join(dir, name);
+		if _, err := os.Stat(target); err == nil {
+			fmt.Printf("Keeping existing %s\n", name);
+			kept++;
+			continue;
+		}
+
+		data, err := ioutil.ReadAll(io.LimitReader(tr, header.Size));
+		if err != nil {
+			return err;
+		}
+		var parent, _ = path.Split(target);
+		if err = os.MkdirAll(parent, 0755); err != nil {
+			return err;
+		}
+		if err = ioutil.WriteFile(target, data, 0644); err != nil {
+			return err;
+		}
+		imported++;
+	}
+	fmt.Printf("Imported %d files into %s, kept %d existing files\n", imported, dir, kept);
+	return nil;
+}
@@ -0,0 +1,273 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Downloading of the HTML documents from Lunchguiden, and classification
+    of the errors so that a broken resolver can be told apart from the
+    site being down.
+*/
+
+package main
+
+import (
+	"fmt"
+	"http"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"rand"
+	"strings"
+	"time"
+)
+
+// Error for responses with another status than 200 OK
+//
+type StatusError struct {
+	Url string;
+	Status string;
+}
+
+func (e *StatusError) String() string {
+	return fmt.Sprintf("%s: %s", e.Url, e.Status);
+}
+
+// Error for downloads that didn't finish within their time budget
+//
+type TimeoutError struct {
+	Url string;
+	Timeout int64;
+}
+
+func (e *TimeoutError) String() string {
+	return fmt.Sprintf("%s: no response within %.1f seconds", e.Url, float64(e.Timeout) / 1e9);
+}
+
+func (e *TimeoutError) Timeout() bool {
+	return true;
+}
+
+// Error for responses larger than -max-size
+//
+type SizeError struct {
+	Url string;
+	Limit int64;
+}
+
+func (e *SizeError) String() string {
+	return fmt.Sprintf("%s: response larger than %d bytes", e.Url, e.Limit);
+}
+
+// Error for responses of a content type that wasn't asked for
+//
+type ContentTypeError struct {
+	Url string;
+	ContentType string;
+}
+
+func (e *ContentTypeError) String() string {
+	return fmt.Sprintf("%s: unexpected content type %s", e.Url, e.ContentType);
+}
+
+// Error for documents that still looked truncated when downloaded again
+//
+type TruncatedError struct {
+	Url string;
+	Reason string;
+}
+
+func (e *TruncatedError) String() string {
+	return fmt.Sprintf("%s: truncated document, %s", e.Url, e.Reason);
+}
+
+// A request for a document
+//
+type Request struct {
+	Method string;
+	Url string;
+	Body string;
+	ContentType string;
+	Charset string;			// Overrides the detected character set
+	Types []string;			// Content types accepted, any if empty
+}
+
+type fetchResult struct {
+	data []byte;
+	err os.Error;
+}
+
+// Makes the request, giving up after timeout nanoseconds.
+// A timeout of zero or less means no time limit.
+// NOTE: The download isn't aborted on timeout, it's left to finish in the
+//	 background while the run continues.
+//
+func FetchWithin(r *Request, timeout int64) ([]byte, os.Error) {
+	if timeout <= 0 {
+		return FetchRequest(r);
+	}
+
+	var done = make(chan fetchResult, 1);
+	go func() {
+		data, err := FetchRequest(r);
+		done <- fetchResult{ data, err };
+	}();
+
+	select {
+	case result := <-done:
+		return result.data, result.err;
+	case <-time.After(timeout):
+	}
+	return nil, &TimeoutError{ r.Url, timeout };
+}
+
+// Least time in nanoseconds between two requests to the site, and when
+// the last request was made
+//
+var requestDelay int64 = 0;
+var lastRequest int64 = 0;
+
+// Bytes of all documents downloaded during the run
+//
+var bytesFetched int64 = 0;
+
+// Largest response read, in bytes, so a URL pointing at something huge
+// can't use up the memory
+//
+var maxResponseSize int64 = 5 << 20;
+
+// Waits until the request delay has passed since the last request
+//
+func Throttle() {
+	var wait = lastRequest + requestDelay - time.Nanoseconds();
+	if wait > 0 {
+		time.Sleep(wait);
+	}
+	lastRequest = time.Nanoseconds();
+}
+
+// Waits a random time up to the given number of seconds, so runs of
+// several cities started at the same time don't hit the site at once
+//
+func Jitter(seconds float64) {
+	if seconds <= 0 {
+		return;
+	}
+	rand.Seed(time.Nanoseconds() + int64(os.Getpid()));
+	var wait = int64(rand.Float64() * seconds * 1e9);
+	fmt.Printf("Waiting %.1f seconds before the first request\n", float64(wait) / 1e9);
+	time.Sleep(wait);
+}
+
+// Downloads the document at the URL
+//
+func Fetch(url string) ([]byte, os.Error) {
+	return FetchRequest(&Request{ Method: "GET", Url: url });
+}
+
+// Makes the request and returns the document, converted to UTF-8
+//
+func FetchRequest(r *Request) ([]byte, os.Error) {
+	if r.Method != "GET" && r.Method != "POST" {
+		return nil, os.NewError("unsupported request method " + r.Method);
+	}
+
+	Throttle();
+	var fault = pickFault(r.Url);
+	if err := injectFault(fault, r.Url); err != nil {
+		return nil, err;
+	}
+	res, err := Do(r);
+	if err != nil {
+		return nil, err;
+	}
+	defer res.Body.Close();
+
+	fmt.Printf("OK, response is %s length is %d\n", res.Status, res.ContentLength);
+	if res.StatusCode != http.StatusOK {
+		return nil, &StatusError{ r.Url, res.Status };
+	}
+	if res.ContentLength > maxResponseSize {
+		return nil, &SizeError{ r.Url, maxResponseSize };
+	}
+	var contentType = res.GetHeader("Content-Type");
+	if len(r.Types) > 0 && contentType != "" {
+		var mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";", 2)[0]));
+		if !contains(r.Types, mediaType) {
+			return nil, &ContentTypeError{ r.Url, contentType };
+		}
+	}
+
+	// One byte more than the limit is read to tell a document of exactly
+	// the limit from a larger one
+	//
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseSize + 1));
+	if err != nil {
+		return nil, err;
+	}
+	if int64(len(data)) > maxResponseSize {
+		return nil, &SizeError{ r.Url, maxResponseSize };
+	}
+	data = truncateFault(fault, data);
+	bytesFetched += int64(len(data));
+	return ToUTF8(data, DetectCharset(r.Charset, res.GetHeader("Content-Type"), data)), nil;
+}
+
+// Tells why a downloaded document looks truncated: smaller than minSize
+// bytes or without the marker. Returns an empty string if it looks whole.
+//
+func Suspicious(data []byte, minSize int, marker string) string {
+	if len(data) < minSize {
+		return fmt.Sprintf("only %d bytes", len(data));
+	}
+	if marker != "" && strings.Index(string(data), marker) < 0 {
+		return "no " + marker;
+	}
+	return "";
+}
+
+// Returns the class of a download error: dns, timeout, connect, tls,
+// http-status, too-large, content-type, truncated, network, robots or
+// other
+//
+func ClassifyError(err os.Error) string {
+	switch e := err.(type) {
+	case *http.URLError:
+		return ClassifyError(e.Error);
+	case *StatusError:
+		return "http-status";
+	case *TruncatedError:
+		return "truncated";
+	case *SizeError:
+		return "too-large";
+	case *ContentTypeError:
+		return "content-type";
+	case *net.DNSError:
+		return "dns";
+	case *net.OpError:
+		if class := ClassifyError(e.Error); class != "other" {
+			return class;
+		}
+		if e.Timeout() {
+			return "timeout";
+		}
+		if e.Op == "dial" {
+			return "connect";
+		}
+		return "network";
+	}
+
+	if t, ok := err.(interface { Timeout() bool }); ok && t.Timeout() {
+		return "timeout";
+	}
+
+	var text = err.String();
+	if strings.Index(text, "robots.txt") >= 0 {
+		return "robots";
+	}
+	if strings.Index(text, "tls:") >= 0 || strings.Index(text, "x509:") >= 0 {
+		return "tls";
+	}
+	return "other";
+}
@@ -0,0 +1,167 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Fuzzing of the parser (-fuzz): the archived HTML documents are mutated
+    at random, cut off, spliced and sprinkled with stray tags, and every
+    parser strategy must return without panicking, within a time limit
+    and with a result that isn't larger than the document could hold.
+    Documents that fail are kept for reproducing with "parse <file>".
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"rand"
+	"strings"
+	"time"
+)
+
+// Longest time one strategy may take for a document
+//
+const fuzzTimeout = 5e9;
+
+// Snippets inserted into the documents, the markup the parsers look for
+// and the characters that end things early
+//
+var fuzzTokens = []string {
+	"<td>", "</td>", "<tr>", "</tr>", "<center>", "</center>", "<li>", "<br>",
+	"<img src=\"lunchlogo/fuzz.gif\">", "SRC=\"lunchlogo/", "lunchlogo/",
+	"<", ">", "\"", "'", "&", "&amp", "&#", "<!--", "\x00", "\xff", "å",
+};
+
+// Mutates the archived HTML documents in the archive directory the given
+// number of times each and returns the number of failures. Failing
+// documents are written to the fuzz directory of the archive.
+//
+func Fuzz(dir string, iterations int, seed int64) (int, os.Error) {
+	files, err := ioutil.ReadDir(fmt.Sprintf("%s/html", dir));
+	if err != nil {
+		return 0, err;
+	}
+
+	// The parsers print about every unknown logo, which would drown the
+	// report
+	//
+	devNull, err := os.Open("/dev/null", os.O_WRONLY, 0);
+	if err != nil {
+		return 0, err;
+	}
+	defer devNull.Close();
+	var console = os.Stdout;
+	os.Stdout = devNull;
+	defer func() { os.Stdout = console; }();
+
+	var r = rand.New(rand.NewSource(seed));
+	var failures, documents = 0, 0;
+	for i := 0; i < len(files); i++ {
+		if !strings.HasSuffix(files[i].Name, ".html") {
+			continue;
+		}
+		inData, err := ioutil.ReadFile(fmt.Sprintf("%s/html/%s", dir, files[i].Name));
+		if err != nil {
+			return failures, err;
+		}
+		documents++;
+
+		for n := 0; n < iterations; n++ {
+			var mutated = mutate(r, inData);
+			for _, strategy := range strategies {
+				var reason = fuzzStrategy(strategy, string(mutated));
+				if reason == "" {
+					continue;
+				}
+				failures++;
+				var hashStr, _ = GenerateHash(mutated);
+				var path = fmt.Sprintf("%s/fuzz/%s.html", dir, hashStr);
+				fmt.Fprintf(console, "FAIL %s parser on %s mutated: %s, kept as %s\n", strategy.Name, files[i].Name, reason, path);
+				if err = os.MkdirAll(dir + "/fuzz", 0755); err == nil {
+					err = ioutil.WriteFile(path, mutated, 0644);
+				}
+				if err != nil {
+					return failures, err;
+				}
+			}
+			warnings = make([]Warning, 0);
+		}
+	}
+	fmt.Fprintf(console, "Fuzzed %d documents %d times with seed %d, %d failures\n", documents, iterations, seed, failures);
+	return failures, nil;
+}
+
+// Runs a strategy on a document and returns what went wrong, if anything
+//
+func fuzzStrategy(strategy Strategy, in string) string {
+	var done = make(chan string, 1);
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				done <- fmt.Sprint("panic: ", e);
+			}
+		}();
+		done <- checkBounds(MergeDuplicates(strategy.Parse(in)), in);
+	}();
+
+	select {
+	case reason := <-done:
+		return reason;
+	case <-time.After(fuzzTimeout):
+	}
+	return fmt.Sprintf("no result within %d seconds", fuzzTimeout / 1e9);
+}
+
+// Every restaurant needs a tag of its own, and the texts can't be much
+// longer than the document
+//
+func checkBounds(restaurants []RestData, in string) string {
+	if len(restaurants) > strings.Count(in, "<") {
+		return fmt.Sprintf("%d restaurants from %d tags", len(restaurants), strings.Count(in, "<"));
+	}
+	var size = 0;
+	for i := 0; i < len(restaurants); i++ {
+		size += len(restaurants[i].Menu) + len(restaurants[i].Description);
+	}
+	if size > 4 * len(in) {
+		return fmt.Sprintf("%d bytes of text from %d bytes", size, len(in));
+	}
+	return "";
+}
+
+// Returns a copy of the document with one to eight random changes
+//
+func mutate(r *rand.Rand, in []byte) []byte {
+	var out = bytes.NewBuffer(make([]byte, 0, len(in) + 64));
+	out.Write(in);
+	var data = out.Bytes();
+
+	for changes := 1 + r.Intn(8); changes > 0; changes-- {
+		if len(data) == 0 {
+			data = []byte(fuzzTokens[r.Intn(len(fuzzTokens))]);
+			continue;
+		}
+		var at = r.Intn(len(data));
+		var end = at + r.Intn(len(data) - at + 1);
+
+		switch r.Intn(5) {
+		case 0:
+			data[at] = byte(r.Intn(256));
+		case 1:
+			data = append(data[0:at], data[end:]...);
+		case 2:
+			var chunk = append([]byte(nil), data[at:end]...);
+			data = append(data[0:end], append(chunk, data[end:]...)...);
+		case 3:
+			var token = []byte(fuzzTokens[r.Intn(len(fuzzTokens))]);
+			data = append(data[0:at], append(token, data[at:]...)...);
+		case 4:
+			data = data[0:at];
+		}
+	}
+	return data;
+}
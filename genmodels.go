@@ -0,0 +1,206 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Generation of Kotlin or Java model classes for the output (-genmodels),
+    made from the Go structs by reflection so the models of the Android
+    app can't drift from what's actually written. The types of the Go
+    client (client/model.go) are generated the same way.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Package of the generated classes
+//
+const modelPackage = "se.x539.lunchguiden.model";
+
+// Names of the types in the Go client, for the structs not named the same
+//
+var clientNames = map[string]string {
+	"DataStruct":	"Week",
+	"DayData":	"Day",
+	"RestData":	"Restaurant",
+	"DishData":	"Dish",
+};
+
+// Generates the model classes for the language, kotlin, java or go (the
+// types of the client)
+//
+func GenerateModels(lang string) (string, os.Error) {
+	if lang != "kotlin" && lang != "java" && lang != "go" {
+		return "", os.NewError("unknown language " + lang);
+	}
+
+	var buf = bytes.NewBuffer(make([]byte, 0));
+	fmt.Fprintf(buf, "// Generated by lunchguiden -genmodels %s, schema version %d. Do not edit.\n", lang, SchemaVersion);
+	switch lang {
+	case "go":
+		fmt.Fprintf(buf, "\npackage client\n");
+	case "kotlin":
+		fmt.Fprintf(buf, "package %s\n\n", modelPackage);
+		fmt.Fprintf(buf, "import com.google.gson.annotations.SerializedName\n");
+	default:
+		fmt.Fprintf(buf, "package %s;\n\n", modelPackage);
+		fmt.Fprintf(buf, "import com.google.gson.annotations.SerializedName;\n");
+		fmt.Fprintf(buf, "import java.util.List;\nimport java.util.Map;\n");
+	}
+
+	// Structs are generated in the order they are found, starting with
+	// the top level struct
+	//
+	var queue = []*reflect.StructType { reflect.Typeof(DataStruct{}).(*reflect.StructType) };
+	var done = make(map[string]bool);
+
+	for len(queue) > 0 {
+		var t = queue[0];
+		queue = queue[1:];
+		if done[t.Name()] {
+			continue;
+		}
+		done[t.Name()] = true;
+
+		fmt.Fprintf(buf, "\n");
+		switch lang {
+		case "go":
+			fmt.Fprintf(buf, "type %s struct {\n", clientName(t.Name()));
+		case "kotlin":
+			fmt.Fprintf(buf, "data class %s(\n", t.Name());
+		default:
+			fmt.Fprintf(buf, "public class %s {\n", t.Name());
+		}
+
+		for i := 0; i < t.NumField(); i++ {
+			var field = t.Field(i);
+			if field.PkgPath != "" {
+				continue;		// Unexported fields aren't in the JSON
+			}
+
+			var fieldType = modelType(lang, field.Type, &queue);
+			var name = strings.ToLower(field.Name[0:1]) + field.Name[1:];
+			switch lang {
+			case "go":
+				fmt.Fprintf(buf, "\t%s %s;\n", field.Name, fieldType);
+			case "kotlin":
+				var separator = ",";
+				if i == t.NumField() - 1 {
+					separator = "";
+				}
+				fmt.Fprintf(buf, "    @SerializedName(%q) val %s: %s%s\n", field.Name, name, fieldType, separator);
+			default:
+				fmt.Fprintf(buf, "    @SerializedName(%q) public %s %s;\n", field.Name, fieldType, name);
+			}
+		}
+
+		if lang == "kotlin" {
+			fmt.Fprintf(buf, ")\n");
+		} else {
+			fmt.Fprintf(buf, "}\n");
+		}
+	}
+	return buf.String(), nil;
+}
+
+// Returns the type in the language for a Go type, structs are added to
+// the queue of types to generate. Slices and pointers are nullable since
+// an empty slice and a nil pointer are written as null.
+//
+func modelType(lang string, t reflect.Type, queue *[]*reflect.StructType) string {
+	if lang == "go" {
+		return goType(t, queue);
+	}
+	var kotlin = lang == "kotlin";
+
+	switch v := t.(type) {
+	case *reflect.BoolType:
+		if kotlin {
+			return "Boolean";
+		}
+		return "boolean";
+	case *reflect.IntType:
+		if kotlin {
+			return "Int";
+		}
+		return "int";
+	case *reflect.FloatType:
+		if kotlin {
+			return "Double";
+		}
+		return "double";
+	case *reflect.StringType:
+		return "String";
+	case *reflect.SliceType:
+		if kotlin {
+			return fmt.Sprintf("List<%s>?", boxed(modelType(lang, v.Elem(), queue)));
+		}
+		return fmt.Sprintf("List<%s>", boxed(modelType(lang, v.Elem(), queue)));
+	case *reflect.ArrayType:
+		return fmt.Sprintf("List<%s>", boxed(modelType(lang, v.Elem(), queue)));
+	case *reflect.MapType:
+		return fmt.Sprintf("Map<%s, %s>", boxed(modelType(lang, v.Key(), queue)), boxed(modelType(lang, v.Elem(), queue)));
+	case *reflect.PtrType:
+		if kotlin {
+			return strings.TrimRight(modelType(lang, v.Elem(), queue), "?") + "?";
+		}
+		return boxed(modelType(lang, v.Elem(), queue));
+	case *reflect.StructType:
+		*queue = append(*queue, v);
+		return v.Name();
+	}
+	if kotlin {
+		return "Any?";
+	}
+	return "Object";
+}
+
+// Returns the type in the client for a Go type, the same but with the
+// structs renamed
+//
+func goType(t reflect.Type, queue *[]*reflect.StructType) string {
+	switch v := t.(type) {
+	case *reflect.SliceType:
+		return "[]" + goType(v.Elem(), queue);
+	case *reflect.ArrayType:
+		return fmt.Sprintf("[%d]%s", v.Len(), goType(v.Elem(), queue));
+	case *reflect.MapType:
+		return fmt.Sprintf("map[%s]%s", goType(v.Key(), queue), goType(v.Elem(), queue));
+	case *reflect.PtrType:
+		return "*" + goType(v.Elem(), queue);
+	case *reflect.StructType:
+		*queue = append(*queue, v);
+		return clientName(v.Name());
+	}
+	return t.Name();
+}
+
+// Returns the name of a struct in the client
+//
+func clientName(name string) string {
+	if renamed, found := clientNames[name]; found {
+		return renamed;
+	}
+	return name;
+}
+
+// Returns the boxed Java type for a primitive type, as needed in generics
+//
+func boxed(name string) string {
+	switch name {
+	case "int":
+		return "Integer";
+	case "double":
+		return "Double";
+	case "boolean":
+		return "Boolean";
+	}
+	return strings.TrimRight(name, "?");
+}
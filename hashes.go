@@ -0,0 +1,55 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Short content hashes of every dish and restaurant of a day, so clients
+    can tell exactly which cards changed since their last download.
+*/
+
+package main
+
+import (
+	"json"
+)
+
+// Number of hex digits kept of the md5 sums, plenty for telling the
+// cards of one day apart
+//
+const hashLength = 12;
+
+// Sets the hashes of all dishes and restaurants of the week
+//
+func HashWeek(data *DataStruct) {
+	for day := 0; day < 5; day++ {
+		hashRestaurants(data.Days[day].Restaurants);
+		hashRestaurants(data.Days[day].Specials);
+	}
+}
+
+// Sets the hashes of the restaurants and their dishes. A restaurant is
+// hashed with all its fields, so any change to the card changes it.
+//
+func hashRestaurants(list []RestData) {
+	for i := 0; i < len(list); i++ {
+		var r = &list[i];
+		for j := 0; j < len(r.Dishes); j++ {
+			r.Dishes[j].Hash = "";
+			r.Dishes[j].Hash = shortHash(r.Dishes[j]);
+		}
+		r.Hash = "";
+		r.Hash = shortHash(*r);
+	}
+}
+
+// Returns the start of the md5 sum of the value as JSON
+//
+func shortHash(value interface{}) string {
+	data, err := json.Marshal(value);
+	if err != nil {
+		return "";
+	}
+	var hashStr, _ = GenerateHash(data);
+	return hashStr[0:hashLength];
+}
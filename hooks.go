@@ -0,0 +1,83 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Hooks running external commands at points of a run, configured in the
+    Hooks section of the configuration, for site specific customizations
+    without changing the code. A hook gets the week as JSON on stdin and
+    vetoes the run by exiting with a non-zero status:
+
+      pre_fetch     gets the empty week before anything is downloaded
+      post_parse    gets the parsed week, JSON on stdout replaces it
+      post_publish  gets the published week, can't veto anything
+*/
+
+package main
+
+import (
+	"exec"
+	"fmt"
+	"io/ioutil"
+	"json"
+	"os"
+	"strings"
+)
+
+var hooks = []string { "pre_fetch", "post_parse", "post_publish" };
+
+// Runs the hook, if configured, with the week on stdin. The returned
+// week is the one written to stdout by the hook, or the same week if it
+// wrote nothing.
+//
+func RunHook(name string, data *DataStruct) (*DataStruct, os.Error) {
+	var argv = config.Hooks[name];
+	if len(argv) == 0 {
+		return data, nil;
+	}
+
+	input, err := json.Marshal(data);
+	if err != nil {
+		return nil, err;
+	}
+	path, err := exec.LookPath(argv[0]);
+	if err != nil {
+		return nil, err;
+	}
+
+	fmt.Printf("Running %s hook %s\n", name, strings.Join(argv, " "));
+	cmd, err := exec.Run(path, argv, os.Environ(), "", exec.Pipe, exec.Pipe, exec.PassThrough);
+	if err != nil {
+		return nil, err;
+	}
+
+	// Written concurrently, a hook may start writing before it has read
+	// all of its input
+	//
+	go func() {
+		cmd.Stdin.Write(input);
+		cmd.Stdin.Close();
+	}();
+	output, err := ioutil.ReadAll(cmd.Stdout);
+	if err != nil {
+		return nil, err;
+	}
+
+	status, err := cmd.Wait(0);
+	if err != nil {
+		return nil, err;
+	}
+	if status.ExitStatus() != 0 {
+		return nil, os.NewError(fmt.Sprintf("%s hook vetoed the run, exit status %d", name, status.ExitStatus()));
+	}
+
+	if len(strings.TrimSpace(string(output))) == 0 {
+		return data, nil;
+	}
+	var result = new(DataStruct);
+	if err = json.Unmarshal(output, result); err != nil {
+		return nil, os.NewError(name + " hook wrote invalid JSON: " + err.String());
+	}
+	return result, nil;
+}
@@ -0,0 +1,72 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Extraction of the serving hours from the description of a restaurant
+    ("Lunch serveras 11-14", "kl. 11.00-13.30"), so the app can show
+    which restaurants are serving right now.
+*/
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Hours struct {
+	From string;			// Local time as HH:MM
+	To string;
+}
+
+// A time interval like 11-14, 11.00-13.30 or 11:00 till 14:00
+//
+var rx_hours = regexp.MustCompile("([0-9][0-9]?)([.:]([0-9][0-9]))? *(-|–|&ndash;|till) *([0-9][0-9]?)([.:]([0-9][0-9]))?");
+
+// Words that must come before the interval on the same line, so prices
+// and phone numbers aren't taken for hours
+//
+var rx_servingWords = regexp.MustCompile("lunch|server|kl|öppet|mellan");
+
+// Returns the serving hours found in the text, or nil
+//
+func ExtractHours(text string) *Hours {
+	var lines = strings.Split(strings.ToLower(DecodeEntities(text)), "\n", -1);
+
+	for i := 0; i < len(lines); i++ {
+		var m = rx_hours.FindStringSubmatchIndex(lines[i]);
+		if m == nil || !rx_servingWords.MatchString(lines[i][0:m[0]]) {
+			continue;
+		}
+		var match = rx_hours.FindStringSubmatch(lines[i]);
+
+		from, ok1 := clockTime(match[1], match[3]);
+		to, ok2 := clockTime(match[5], match[7]);
+		if !ok1 || !ok2 || from >= to {
+			continue;
+		}
+		return &Hours{ from, to };
+	}
+	return nil;
+}
+
+// Formats hours and minutes as HH:MM, false if it isn't a plausible
+// time of a lunch
+//
+func clockTime(hours string, minutes string) (string, bool) {
+	h, err := strconv.Atoi(hours);
+	if err != nil || h < 6 || h > 23 {
+		return "", false;
+	}
+	var m = 0;
+	if minutes != "" {
+		if m, err = strconv.Atoi(minutes); err != nil || m > 59 {
+			return "", false;
+		}
+	}
+	return fmt.Sprintf("%02d:%02d", h, m), true;
+}
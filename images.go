@@ -0,0 +1,188 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Resolving of the logo image links against the image base of the
+    provider, rejecting links to hosts that aren't allowed, checking them
+    so that dead links are handled before they show up as broken images
+    in the app, and embedding of the logos as data URIs for fully
+    self-contained output.
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"http"
+	"io"
+	"io/ioutil"
+	"log"
+	"lunchguiden/client"
+	"os"
+	"strings"
+)
+
+// Base URL of the logos of providers without one of their own
+//
+const defaultImageBase = "http://service.dt.se/lunch/";
+
+// Turns the image links of the parsed restaurants into URLs on the image
+// base, and drops the ones that end up on a host that isn't allowed or
+// aren't http, so links injected in the page never reach the clients
+//
+func ResolveImages(list []RestData, base string, hosts []string, day int) {
+	if base == "" {
+		base = defaultImageBase;
+	}
+	for i := 0; i < len(list); i++ {
+		var r = &list[i];
+		if r.ImageUrl == "" {
+			continue;
+		}
+		r.ImageUrl = client.ResolveLocation(base, r.ImageUrl);
+
+		u, err := http.ParseURL(r.ImageUrl);
+		if err == nil && (u.Scheme == "http" || u.Scheme == "https") && contains(hosts, strings.ToLower(u.Host)) {
+			continue;
+		}
+		Warn("image-rejected", day, r.Id, fmt.Sprintf("Image of %s is not on an allowed host: %s", r.Id, r.ImageUrl));
+		r.ImageUrl = "";
+	}
+}
+
+// Results of the checked image URLs, the same logos are used on all
+// days of the week so each of them is only checked once per run
+//
+var imageStatus = make(map[string]bool);
+
+// Checks if the image URL can be fetched with a HEAD request
+//
+func ImageOk(url string) bool {
+	if ok, found := imageStatus[url]; found {
+		return ok;
+	}
+
+	Throttle();
+	res, err := Do(&Request{ Method: "HEAD", Url: url });
+	if err != nil {
+		log.Println(err);
+	}
+	var ok = err == nil && res.StatusCode == http.StatusOK;
+	if res != nil && res.Body != nil {
+		res.Body.Close();
+	}
+
+	imageStatus[url] = ok;
+	return ok;
+}
+
+// Checks the images of all restaurants and handles the broken ones with
+// the given mode: "flag" only marks them, "drop" removes the URL and
+// "placeholder" replaces it with the placeholder URL
+//
+func CheckImages(list []RestData, mode string, placeholder string) {
+	for i := 0; i < len(list); i++ {
+		if list[i].ImageUrl == "" || ImageOk(list[i].ImageUrl) {
+			continue;
+		}
+
+		log.Printf("WARNING: Broken image for %s: %s\n", list[i].Id, list[i].ImageUrl);
+		list[i].ImageBroken = true;
+
+		switch mode {
+		case "drop":
+			list[i].ImageUrl = "";
+		case "placeholder":
+			list[i].ImageUrl = placeholder;
+		}
+	}
+}
+
+// A downloaded logo image
+//
+type Image struct {
+	Data []byte;
+	ContentType string;
+}
+
+// Downloaded logos, the same logos are used on all days of the week so
+// each of them is only downloaded once per run
+//
+var downloaded = make(map[string]*Image);
+
+// Downloads the image, or returns it from the cache if it's already
+// been downloaded during this run. Images larger than -max-size aren't
+// read.
+//
+func DownloadImage(url string) (*Image, os.Error) {
+	if image, found := downloaded[url]; found {
+		return image, nil;
+	}
+
+	Throttle();
+	res, err := Do(&Request{ Method: "GET", Url: url });
+	if err != nil {
+		return nil, err;
+	}
+	defer res.Body.Close();
+
+	if res.StatusCode != http.StatusOK {
+		return nil, os.NewError(fmt.Sprintf("%s: %s", url, res.Status));
+	}
+	if res.ContentLength > maxResponseSize {
+		return nil, &SizeError{ url, maxResponseSize };
+	}
+
+	// Same limit as for the documents, a huge image would use up the
+	// memory just as well
+	//
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseSize + 1));
+	if err != nil {
+		return nil, err;
+	}
+	if int64(len(data)) > maxResponseSize {
+		return nil, &SizeError{ url, maxResponseSize };
+	}
+
+	var image = &Image{ Data: data, ContentType: res.GetHeader("Content-Type") };
+	if image.ContentType == "" {
+		image.ContentType = "image/gif";
+	}
+	downloaded[url] = image;
+	return image, nil;
+}
+
+// Returns the image as a data URI, or an empty string if it's larger
+// than max bytes or can't be downloaded
+//
+func EmbedImage(url string, max int) string {
+	image, err := DownloadImage(url);
+	if err != nil {
+		log.Println(err);
+		return "";
+	}
+	if len(image.Data) > max {
+		return "";
+	}
+
+	var encoded = make([]byte, base64.StdEncoding.EncodedLen(len(image.Data)));
+	base64.StdEncoding.Encode(encoded, image.Data);
+	return fmt.Sprintf("data:%s;base64,%s", image.ContentType, encoded);
+}
+
+// Replaces the image URLs of all restaurants with embedded data URIs,
+// images that can't be embedded keep their URL
+//
+func EmbedImages(list []RestData, max int) {
+	for i := 0; i < len(list); i++ {
+		if list[i].ImageUrl == "" {
+			continue;
+		}
+		if uri := EmbedImage(list[i].ImageUrl, max); uri != "" {
+			list[i].ImageUrl = uri;
+		}
+	}
+}
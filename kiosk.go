@@ -0,0 +1,120 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Plain text of today's menus for the e-ink display in the lobby
+    (-kiosk), wrapped at word boundaries to the columns of the display
+    and split into pages of its rows, separated by form feeds.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Parses the size of the display given as columns x rows, like 40x12
+//
+func ParseKioskSize(s string) (int, int, os.Error) {
+	var columns, rows int;
+	if n, _ := fmt.Sscanf(s, "%dx%d", &columns, &rows); n != 2 || columns < 10 || rows < 3 {
+		return 0, 0, os.NewError("invalid display size " + s);
+	}
+	return columns, rows, nil;
+}
+
+// Writes the pages of today's menus to the file
+//
+func WriteKiosk(file string, data *DataStruct, year int64, columns int, rows int) os.Error {
+	var day = Today(data.Week, year);
+	var title = Wrap(fmt.Sprintf("Lunch %s, %s", data.City, data.Days[day].Name), columns)[0];
+
+	// Every restaurant is a block of lines that is kept on one page if
+	// it fits
+	//
+	var blocks = make([][]string, 0);
+	var list = append(data.Days[day].Restaurants, data.Days[day].Specials...);
+	for i := 0; i < len(list); i++ {
+		var r = &list[i];
+		var name = SanitizeText(r.Name, "plain");
+		if name == "" {
+			name = r.Id;
+		}
+		var block = Wrap(name, columns);
+
+		if r.Closed {
+			block = append(block, Wrap("  " + SanitizeText(r.ClosedNote, "plain"), columns)...);
+		}
+
+		// Weekly menus may only be kept on Monday, and the menus aren't
+		// split into dishes with dish_splitting turned off
+		//
+		var dishes = WeeklyDishes(data, r);
+		for j := 0; j < len(dishes); j++ {
+			block = append(block, Wrap("  " + SanitizeText(dishes[j].Text, "plain"), columns)...);
+		}
+		if len(dishes) == 0 && !r.Closed {
+			var lines = strings.Split(SanitizeText(WeeklyMenuText(data, r), "plain"), "\n", -1);
+			for j := 0; j < len(lines); j++ {
+				if lines[j] != "" {
+					block = append(block, Wrap("  " + lines[j], columns)...);
+				}
+			}
+		}
+		blocks = append(blocks, block);
+	}
+
+	var pages = make([]string, 0);
+	var page = []string { title };
+	for i := 0; i < len(blocks); i++ {
+		for j := 0; j < len(blocks[i]); j++ {
+			var fits = len(page) + len(blocks[i]) - j + 1 <= rows;
+			if len(page) == rows || (j == 0 && !fits && len(page) > 1) {
+				pages = append(pages, strings.Join(page, "\n"));
+				page = []string { title };
+			}
+			if j == 0 && len(page) > 1 {
+				page = append(page, "");
+			}
+			page = append(page, blocks[i][j]);
+		}
+	}
+	pages = append(pages, strings.Join(page, "\n"));
+
+	return WriteFile(file, []byte(strings.Join(pages, "\n\f") + "\n"));
+}
+
+// Wraps the text into lines of at most the given number of characters,
+// breaking at spaces and only breaking words longer than a line. Lines
+// continued from an indented line keep the indentation.
+//
+func Wrap(text string, columns int) []string {
+	var indent = text[0:len(text) - len(strings.TrimLeft(text, " "))];
+	var words = strings.Fields(text);
+	var lines = make([]string, 0, 1);
+	var line = []int(indent);
+
+	for i := 0; i < len(words); i++ {
+		var word = []int(words[i]);
+		if len(line) > len(indent) && len(line) + 1 + len(word) > columns {
+			lines = append(lines, string(line));
+			line = []int(indent);
+		}
+		if len(line) > len(indent) {
+			line = append(line, ' ');
+		}
+		for len(line) + len(word) > columns {
+			var n = columns - len(line);
+			line = append(line, word[0:n]...);
+			lines = append(lines, string(line));
+			line = []int(indent);
+			word = word[n:];
+		}
+		line = append(line, word...);
+	}
+	return append(lines, string(line));
+}
@@ -0,0 +1,87 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Detection of changed logos. A new logo file is the usual reason for a
+    restaurant suddenly becoming unknown, so every change is written to
+    the changelog in the archive.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"json"
+	"log"
+	"os"
+)
+
+// The last seen logo of a restaurant
+//
+type LogoState struct {
+	Url string;
+	Hash string;
+}
+
+// Returns the path of the file with the last seen logos of a city
+//
+func LogoStatePath(dir string, city string) string {
+	return fmt.Sprintf("%s/logos/%s.json", dir, Slug(city));
+}
+
+// Compares the logos of the week with the last seen logos of the city and
+// records new, changed and unknown logos in the changelog
+//
+func DetectLogoChanges(dir string, data *DataStruct) os.Error {
+	var state = make(map[string]LogoState);
+	var path = LogoStatePath(dir, data.City);
+
+	if stored, err := ioutil.ReadFile(path); err == nil {
+		if err = json.Unmarshal(stored, &state); err != nil {
+			return err;
+		}
+	}
+
+	for day := 0; day < 5; day++ {
+		var list = append(data.Days[day].Restaurants, data.Days[day].Specials...);
+
+		for i := 0; i < len(list); i++ {
+			var r = &list[i];
+			if r.ImageUrl == "" {
+				continue;
+			}
+
+			image, err := DownloadImage(r.ImageUrl);
+			if err != nil {
+				log.Println(err);
+				continue;
+			}
+			var hashStr, _ = GenerateHash(image.Data);
+			var last, found = state[r.Id];
+
+			switch {
+			case !found && r.Name == "":
+				AppendChangelog(dir, data, fmt.Sprintf("unknown logo %s", r.ImageUrl));
+			case !found:
+				AppendChangelog(dir, data, fmt.Sprintf("new logo for %s: %s", r.Id, r.ImageUrl));
+			case last.Url != r.ImageUrl:
+				AppendChangelog(dir, data, fmt.Sprintf("logo file for %s changed from %s to %s", r.Id, last.Url, r.ImageUrl));
+			case last.Hash != hashStr:
+				AppendChangelog(dir, data, fmt.Sprintf("logo content for %s changed (%s)", r.Id, r.ImageUrl));
+			}
+			state[r.Id] = LogoState{ Url: r.ImageUrl, Hash: hashStr };
+		}
+	}
+
+	stored, err := json.Marshal(state);
+	if err != nil {
+		return err;
+	}
+	if err = os.MkdirAll(fmt.Sprintf("%s/logos", dir), 0755); err != nil {
+		return err;
+	}
+	return ioutil.WriteFile(path, stored, 0644);
+}
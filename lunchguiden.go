@@ -34,10 +34,8 @@
 package main
 
 import (
-	"http"
 	"fmt"
 	"os"
-	"io/ioutil"
 	"log"
 	"regexp"
 	"strings"
@@ -45,113 +43,1089 @@ import (
 	"crypto/md5"
 	"json"
 	"bytes"
+	"time"
+	"math"
 )
 
-// Three structs needed for JSON output
+// Structs needed for JSON output
 //
 type DataStruct struct {
+	SchemaVersion int;
+	Generator string;
 	City string;
+	Year int;			// ISO year of the week, which differs from the calendar year around new year
 	Week int;
 	Days [5]DayData;
+	Summary *Summary;
+	Warnings []Warning;
+	Dropped []string;		// What was left out to keep within -max-bytes
 }
 type DayData struct {
 	Day int;
 	Name string;
+	Date string;			// Calendar date, like 2011-02-14
+	Snapshot string;
+	Holiday string;
+	Parser string;
+	Confidence float64;
+	Error string;
+	Weather *Weather;
 	Restaurants []RestData;
+	Specials []RestData;
 }
 type RestData struct {
+	Id string;
 	Name string;
 	ImageUrl string;
+	ImageBroken bool;
 	Description string;
 	Menu string;
+	Special string;
+	WeeklyMenu bool;
+	Closed bool;
+	ClosedNote string;
+	ServingHours *Hours;
+	Phone string;
+	Website string;
+	DistanceMeters int;
+	WalkMinutes int;
+	Truncated bool;
+	Source string;
+	Hash string;
+	TypicalDays []int;		// Weekdays (0 = monday) it usually has a menu, from the archive
+	Dishes []DishData;
+}
+type DishData struct {
+	Text string;
+	Categories []string;
+	Price string;
+	PriceOre []int;
+	Currency string;
+	Nutrition []string;
+	Hash string;
 }
 
 // Input values
 // 
 var url = flag.String("url", "", "URL to lunchguiden");
-var out = flag.String("out", "", "Output file");
+var out = flag.String("out", "", "Output file, - for stdout, may contain {city}, {year} and {week} like {city}/{year}/W{week}/menu.json");
+var ndjson = flag.String("ndjson", "", "File to write the week to as newline delimited JSON, a line per day followed by one per restaurant on it (optional)");
+var current = flag.String("current", "", "Link to keep pointing at the last published week, like {city}/current.json (optional)");
+var mode = flag.String("mode", "0644", "Mode of the published files, in octal");
+var owner = flag.String("owner", "", "Owner of the published files as uid:gid, when running as root");
 var city = flag.String("city", "", "Textual representation of the city");
 var week = flag.Int("week", 0, "What week number to download");
+var year = flag.Int("year", 0, "What ISO year the week is in, defaults to the one of today");
+var configFile = flag.String("config", "", "Configuration file (optional)");
+var providerName = flag.String("provider", "lunchguiden", "Comma separated providers in the configuration describing how to request a day, merged in order");
+var archive = flag.String("archive", "", "Directory to archive the raw HTML in (optional)");
+var days = flag.String("days", "Mandag,Tisdag,Onsdag,Torsdag,Fredag", "Comma separated weekday values used in the URL (URL encoded), unless the provider has its own");
+var lang = flag.String("lang", "sv", "Language of the weekday names in the output (sv or en)");
+var names = flag.String("names", "", "Comma separated weekday names for the output, overrides -lang");
+var specials = flag.Bool("specials", false, "Move restaurants with special menus to a separate list");
+var order = flag.String("order", "source", "Order of the restaurants: source, alpha, favorites or distance");
+var favorites = flag.String("favorites", "", "Comma separated restaurant ids listed first with -order=favorites");
+var validateOutput = flag.Bool("validate", true, "Validate the output against the schema before writing it");
+var checkImages = flag.String("check-images", "", "Check the image links and flag, drop or placeholder the broken ones");
+var placeholder = flag.String("placeholder", "", "Image URL used for broken images with -check-images=placeholder");
+var embedImages = flag.Bool("embed-images", false, "Embed the logos in the output as data URIs");
+var embedMax = flag.Int("embed-max", 16384, "Largest logo in bytes to embed with -embed-images");
+var logoChanges = flag.Bool("logo-changes", false, "Record new and changed logos in the changelog of the archive");
+var debugDump = flag.String("debug-dump", "", "Directory to write the HTML fragment and parsed result of every restaurant to");
+var staging = flag.String("staging", "", "Directory to hold suspicious output in for review instead of publishing it");
+var minConfidence = flag.Float64("min-confidence", 0.5, "Hold the output for review if a day has a lower confidence score");
+var maxDiff = flag.Float64("max-diff", 0.5, "Hold the output for review if a larger fraction of the published menus changed");
+var maxDrop = flag.Float64("max-drop", 0.3, "Alert if the number of restaurants dropped by more than this fraction since last week");
+var alertUrl = flag.String("alert-url", "", "URL to post alerts to (optional)");
+var metricsFile = flag.String("metrics", "", "File to write the metrics of the run to, in the Prometheus text format");
+var noWarnings = flag.Bool("no-warnings", false, "Leave the data quality warnings out of the output");
+var bench = flag.String("bench", "", "Benchmark parsing of the HTML documents in this archive directory and exit");
+var benchN = flag.Int("bench-n", 100, "Iterations per document with -bench");
+var benchBaseline = flag.String("bench-baseline", "", "Fail -bench if it's slower than the results in this file");
+var benchSave = flag.String("bench-save", "", "Save the results of -bench to this file, as a baseline");
+var benchThreshold = flag.Float64("bench-threshold", 0.2, "Slowdown compared to the baseline that fails -bench, 0.2 for 20%");
+var fuzz = flag.String("fuzz", "", "Parse mutated copies of the HTML documents in this archive directory and exit");
+var fuzzN = flag.Int("fuzz-n", 100, "Mutations per document with -fuzz");
+var fuzzSeed = flag.Int64("fuzz-seed", 0, "Seed of the mutations with -fuzz, from the time if 0");
+var cpuProfile = flag.String("cpuprofile", "", "Write a CPU profile of the run to this file");
+var memProfile = flag.String("memprofile", "", "Write a memory profile of the run to this file");
+var timeout = flag.Int("timeout", 0, "Time budget in seconds for downloading the whole week, 0 for no limit");
+var dayTimeout = flag.Int("day-timeout", 60, "Time budget in seconds for downloading a day, 0 for no limit");
+var robots = flag.String("robots", "fetch", "Rules to follow: fetch (the robots.txt of the site), ignore, or a robots.txt file");
+var maxBytes = flag.Int("max-bytes", 0, "Size budget of the output in bytes for mobile users, descriptions and then the longest menus are dropped to keep within it");
+var maxSize = flag.Int("max-size", 5 << 20, "Largest response read, in bytes");
+var minSize = flag.Int("min-size", 0, "Download a day again if the document is smaller than this many bytes");
+var marker = flag.String("marker", "", "Download a day again if the document doesn't contain this text, like lunchlogo/");
+var refetchDelay = flag.Float64("refetch-delay", 10, "Seconds to wait before downloading a truncated day again");
+var delay = flag.Float64("delay", 0, "Least number of seconds between requests, robots.txt may make it longer");
+var jitter = flag.Float64("jitter", 0, "Wait a random number of seconds up to this before the first request");
+var caFile = flag.String("ca-file", "", "PEM file with the CA certificates to trust for https, instead of the system ones");
+var insecure = flag.Bool("insecure", false, "Don't verify TLS certificates, DANGEROUS");
+var ipVersion = flag.String("ip", "any", "IP version of the outbound connections: 4, 6 or any");
+var sourceAddr = flag.String("source", "", "Source address of the outbound connections (optional)");
+var watch = flag.String("watch", "", "Run the command of a city when a file named after it is dropped in this directory");
+var watchCommand = flag.String("watch-cmd", "./{city}.sh", "Command run for a trigger in -watch, {city} is replaced by the file name");
+var version = flag.Bool("version", false, "Print the version and build information and exit");
+var genModels = flag.String("genmodels", "", "Print model classes of the output for kotlin or java, or the types of the client for go, and exit");
+var reparse = flag.String("reparse", "", "Re-parse the archived HTML of the weeks from this week on, like 2024-W01 or 7 for week 7 of -year, into the archive, instead of publishing");
+var export = flag.String("export", "", "Write the whole archive to this tarball, gzipped unless named .tar, and exit");
+var importFile = flag.String("import", "", "Unpack a tarball made with -export, or the .chunks index of one, into the archive and exit");
+var migrateArchive = flag.Bool("migrate-archive", false, "Rename the weeks archived without their year, like v7.json, to 2011-W07.json and exit");
+var exportChunks = flag.Bool("export-chunks", false, "Also cut the -export into content addressed pieces for mirrors, best with an uncompressed .tar");
+var dedupWeekly = flag.Bool("dedup-weekly", false, "Only keep the menus that are the same all week on Monday");
+var textProfile = flag.String("text", "raw", "Shape of descriptions and menus: raw (as parsed), plain, html (limited) or markdown");
+var maxMenu = flag.Int("max-menu", 0, "Longest menu in characters, longer ones are truncated, 0 for no limit");
+var maxDescription = flag.Int("max-description", 0, "Longest description in characters, longer ones are truncated, 0 for no limit");
+var nutrition = flag.Bool("nutrition", false, "Tag the dishes with rough nutrition hints from the keywords in the configuration");
+var weather = flag.String("weather", "", "Add the forecast at lunch for this location, given as lat,lon, to the days");
+var officeLocation = flag.String("office", "", "Location of the office as lat,lon, for walking distances to the restaurants");
+var osrm = flag.String("osrm", "", "URL of an OSRM server for walking distances along the streets (optional)");
+var todayCard = flag.String("today-card", "", "File to write the card of today for home screen widgets to (optional)");
+var cardN = flag.Int("card-n", 3, "Number of favorite restaurants on the card of today");
+var voiceFeed = flag.String("voice", "", "File to write the question and answer feed of the voice assistant to (optional)");
+var kiosk = flag.String("kiosk", "", "File to write today's menus as paginated plain text for the lobby display to (optional)");
+var kioskSize = flag.String("kiosk-size", "40x12", "Columns and rows of the lobby display");
+var todayOnly = flag.Bool("today", false, "Only download today's menus and merge them into the archived week");
+var canteen = flag.String("canteen", "", "JSON file with the menus of internal canteens to add to the restaurants (optional)");
+var pushgateway = flag.String("pushgateway", "", "URL of a Prometheus Pushgateway to push the metrics of the run to");
+var chaosSpec = flag.String("chaos", "", "Inject faults in the downloads for testing, like timeout=0.1,error=0.1,truncate=0.2,slow=0.1");
+var deadline = flag.Int("deadline", 0, "End the run with a dump of the goroutines if it takes longer than this many seconds");
+var deadWeeks = flag.Int("dead-weeks", 0, "Report restaurants in the archive not seen for this many weeks");
+var holidays = flag.String("holidays", "mark", "What to do on public holidays: mark, skip (don't download) or ignore");
 
+// Display names of the weekdays in the supported languages
+//
+var weekdayNames = map[string][]string {
+	"sv": []string { "Måndag", "Tisdag", "Onsdag", "Torsdag", "Fredag" },
+	"en": []string { "Monday", "Tuesday", "Wednesday", "Thursday", "Friday" },
+};
 
-func main() {
-	var (
-		res *http.Response;
-		err os.Error;
-		inData []byte;
-	)
 
-	// Textual names of the weekdays, needed for URL generation
-	//
-	weekdays := []string{ "Mandag", "Tisdag", "Onsdag", "Torsdag", "Fredag" };
+func main() {
+	var err os.Error;
 
 	// Parse and validate input 
 	//
 	flag.Parse();
+
+	// Parse a single document with "parse <file>", - reads stdin
+	//
+	var parseInput = "";
+	if flag.Arg(0) == "parse" {
+		if flag.NArg() != 2 {
+			fmt.Println("ERROR: Usage: lunchguiden [flags] parse <file>");
+			flag.PrintDefaults();
+			return;
+		}
+		parseInput = flag.Arg(1);
+		if *out == "" {
+			*out = "-";
+		}
+	}
+
+	// Fetch or re-parse earlier weeks into the archive with "backfill
+	// <weeks>", like 20..32, nothing is published
+	//
+	var backfill = "";
+	if flag.Arg(0) == "backfill" {
+		if flag.NArg() != 2 {
+			fmt.Println("ERROR: Usage: lunchguiden [flags] backfill <weeks>");
+			flag.PrintDefaults();
+			return;
+		}
+		backfill = flag.Arg(1);
+	}
+
+	// Check that the site can be reached and parsed with every provider
+	// with "probe", nothing is published
+	//
+	var probe = flag.Arg(0) == "probe";
+
+	// Only the JSON data may be written to stdout
+	//
+	if *out == "-" {
+		RedirectMessages();
+	}
+
+	// Mode and owner of all published files, also of approved ones
+	//
+	if err = SetupFileMode(*mode); err == nil {
+		err = SetupFileOwner(*owner);
+	}
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err);
+		flag.PrintDefaults();
+		return;
+	}
+
+	maxResponseSize = int64(*maxSize);
+
+	if *version {
+		fmt.Println(VersionString());
+		return;
+	}
+
+	// Profiling of the whole run
+	//
+	if *cpuProfile != "" {
+		stop, err := StartCPUProfile(*cpuProfile);
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+			return;
+		}
+		defer stop();
+	}
+	if *memProfile != "" {
+		defer func() {
+			if err := WriteMemProfile(*memProfile); err != nil {
+				log.Println(err);
+			}
+		}();
+	}
+
+	// Benchmark the parser on archived documents, nothing else is done
+	//
+	if *bench != "" {
+		results, err := Bench(*bench, *benchN);
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+			return;
+		}
+		PrintBench(results);
+
+		if *benchSave != "" {
+			if err = WriteBaseline(*benchSave, results); err != nil {
+				fmt.Printf("ERROR: %s\n", err);
+				os.Exit(1);
+			}
+		}
+		if *benchBaseline != "" {
+			regressions, err := CompareBaseline(*benchBaseline, results, *benchThreshold);
+			if err != nil {
+				fmt.Printf("ERROR: %s\n", err);
+				os.Exit(1);
+			}
+			for i := 0; i < len(regressions); i++ {
+				fmt.Printf("REGRESSION: %s\n", regressions[i]);
+			}
+			if len(regressions) > 0 {
+				os.Exit(1);
+			}
+		}
+		return;
+	}
+
+	// Fuzz the parser on archived documents, nothing else is done
+	//
+	if *fuzz != "" {
+		if *fuzzSeed == 0 {
+			*fuzzSeed = time.Nanoseconds();
+		}
+		failures, err := Fuzz(*fuzz, *fuzzN, *fuzzSeed);
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+			os.Exit(1);
+		}
+		if failures > 0 {
+			os.Exit(1);
+		}
+		return;
+	}
+
+	// Generate the model classes of the Android app, nothing else is done
+	//
+	if *genModels != "" {
+		models, err := GenerateModels(*genModels);
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+			return;
+		}
+		fmt.Print(models);
+		return;
+	}
+
+	// Completion script for the shell with "completion <shell>", nothing
+	// else is done
+	//
+	if flag.Arg(0) == "completion" {
+		if flag.NArg() != 2 {
+			fmt.Println("ERROR: Usage: lunchguiden [flags] completion bash|zsh|fish");
+			flag.PrintDefaults();
+			return;
+		}
+		script, err := Completion(flag.Arg(1), *archive);
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+			return;
+		}
+		fmt.Print(script);
+		return;
+	}
+
+	// Refresh cities on triggers from other systems, nothing else is done
+	//
+	if *watch != "" {
+		if err = Watch(*watch, *watchCommand); err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+		}
+		return;
+	}
+
+	// Hung runs are ended, the watchers and benchmarks above run as long
+	// as they need
+	//
+	if *deadline > 0 {
+		StartWatchdog(*deadline);
+	}
+
+	// Verify a published file on a mirror with "verify <url>", nothing
+	// else is done
+	//
+	if flag.Arg(0) == "verify" {
+		if flag.NArg() != 2 {
+			fmt.Println("ERROR: Usage: lunchguiden [flags] verify <url>");
+			flag.PrintDefaults();
+			return;
+		}
+		if err = SetupNetwork(*ipVersion, *sourceAddr); err == nil {
+			err = SetupTLS(*caFile, *insecure);
+		}
+		if err == nil {
+			err = Verify(flag.Arg(1));
+		}
+		if err != nil {
+			fmt.Printf("ERROR: Verification failed: %s\n", err);
+			os.Exit(1);
+		}
+		return;
+	}
+
+	// Move the archive between hosts, nothing else is done
+	//
+	if *export != "" || *importFile != "" {
+		if *archive == "" {
+			fmt.Println("ERROR: No archive specified");
+			flag.PrintDefaults();
+			return;
+		}
+		if *export != "" {
+			err = ExportArchive(*archive, *export);
+			if err == nil && *exportChunks {
+				err = WriteChunks(*export);
+			}
+		} else {
+			err = ImportArchive(*archive, *importFile);
+		}
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+		}
+		return;
+	}
+
+	// Rename the weeks archived by older versions, nothing else is done
+	//
+	if *migrateArchive {
+		if *archive == "" {
+			fmt.Println("ERROR: No archive specified");
+			flag.PrintDefaults();
+			return;
+		}
+		if err = MigrateArchive(*archive); err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+		}
+		return;
+	}
+
+	// Publish a file held for review with "approve <file>", nothing else
+	// is done
+	//
+	if flag.Arg(0) == "approve" {
+		if flag.NArg() != 2 {
+			fmt.Println("ERROR: Usage: lunchguiden [flags] approve <file>");
+			flag.PrintDefaults();
+			return;
+		}
+		outData, err := Approve(flag.Arg(1));
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+			return;
+		}
+
+		var approved = new(DataStruct);
+		if *archive != "" && json.Unmarshal(outData, approved) == nil {
+			if err = ArchiveOutput(*archive, approved.City, approved.Year, approved.Week, outData); err != nil {
+				log.Println(err);
+			}
+		}
+		return;
+	}
+
+	// List the recorded runs, nothing else is done
+	//
+	if flag.Arg(0) == "runs" {
+		if *archive == "" {
+			fmt.Println("ERROR: No archive specified");
+			flag.PrintDefaults();
+			return;
+		}
+		if err = ListRuns(*archive, *city); err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+		}
+		return;
+	}
 	
-	if *url == "" {
+	if *url == "" && *reparse == "" && parseInput == "" {
 		fmt.Println("ERROR: No URL specified");
 		flag.PrintDefaults();
 		return;
 	}
-	if *out == "" {
+	if *out == "" && backfill == "" && *reparse == "" && !probe {
 		fmt.Println("ERROR: No output file specified");
 		flag.PrintDefaults();
 		return;
 	}
-	if *city == "" {
+	if *city == "" && parseInput == "" {
 		fmt.Println("ERROR: No city specified");
 		flag.PrintDefaults();
 		return;
 	}
-	if *week == 0 {
+	if *week == 0 && backfill == "" && *reparse == "" && !probe && parseInput == "" {
 		fmt.Println("ERROR: No week specified");
 		flag.PrintDefaults();
 		return;
 	}
+	if *out == "-" && (*todayOnly || *staging != "") {
+		fmt.Println("ERROR: -today and -staging need an output file");
+		flag.PrintDefaults();
+		return;
+	}
+	if (backfill != "" || *reparse != "" || *todayOnly || *staging != "") && *archive == "" {
+		fmt.Println("ERROR: No archive specified");
+		flag.PrintDefaults();
+		return;
+	}
+	if *year == 0 {
+		isoYear, _ := WeekOf(time.LocalTime());
+		*year = int(isoYear);
+	}
+
+	// The paths of the outputs may put them in a directory layout
+	//
+	*out = OutputPath(*out);
+	*todayCard = OutputPath(*todayCard);
+	*voiceFeed = OutputPath(*voiceFeed);
+	*kiosk = OutputPath(*kiosk);
+	*current = OutputPath(*current);
+	*ndjson = OutputPath(*ndjson);
+	if *configFile != "" {
+		if config, err = LoadConfig(*configFile); err != nil {
+			fmt.Printf("ERROR: Unable to read configuration: %s\n", err);
+			return;
+		}
+	}
+	if *holidays != "mark" && *holidays != "skip" && *holidays != "ignore" {
+		fmt.Println("ERROR: Unknown holiday mode");
+		flag.PrintDefaults();
+		return;
+	}
+	if !ValidTextProfile(*textProfile) {
+		fmt.Println("ERROR: Unknown text profile");
+		flag.PrintDefaults();
+		return;
+	}
+	if *kiosk != "" {
+		if _, _, err = ParseKioskSize(*kioskSize); err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+			flag.PrintDefaults();
+			return;
+		}
+	}
+	if *officeLocation != "" {
+		if office, err = ParseLocation(*officeLocation); err != nil {
+			fmt.Printf("ERROR: Invalid office location: %s\n", err);
+			return;
+		}
+	}
+	if *order != "source" && *order != "alpha" && *order != "favorites" && *order != "distance" {
+		fmt.Println("ERROR: Unknown restaurant order");
+		flag.PrintDefaults();
+		return;
+	}
+	if *checkImages != "" && *checkImages != "flag" && *checkImages != "drop" && *checkImages != "placeholder" {
+		fmt.Println("ERROR: Unknown image check mode");
+		flag.PrintDefaults();
+		return;
+	}
+	if *checkImages == "placeholder" && *placeholder == "" {
+		fmt.Println("ERROR: No placeholder image specified");
+		flag.PrintDefaults();
+		return;
+	}
+
+	// How the days are requested from the site, several providers are
+	// merged in the order given
+	//
+	var providers = strings.Split(*providerName, ",", -1);
+	for i := 0; i < len(providers); i++ {
+		if _, found := config.Providers[providers[i]]; !found {
+			fmt.Printf("ERROR: Unknown provider %s\n", providers[i]);
+			return;
+		}
+	}
+
+	// Weekday values needed for URL generation, these are not the same
+	// as the names written to the output
+	//
+	weekdays := strings.Split(*days, ",", -1);
+	if len(weekdays) != 5 {
+		fmt.Println("ERROR: Exactly five weekdays must be specified");
+		flag.PrintDefaults();
+		return;
+	}
+
+	// Textual names of the weekdays for the output, either given
+	// explicitly or picked by language
+	//
+	var dayNames []string;
+	if *names != "" {
+		dayNames = strings.Split(*names, ",", -1);
+	} else {
+		dayNames = weekdayNames[*lang];
+	}
+	if len(dayNames) != 5 {
+		fmt.Println("ERROR: Unknown language or not exactly five weekday names");
+		flag.PrintDefaults();
+		return;
+	}
+
+	// Settings for outbound and https connections
+	//
+	if err = SetupNetwork(*ipVersion, *sourceAddr); err != nil {
+		fmt.Printf("ERROR: %s\n", err);
+		return;
+	}
+	if err = SetupChaos(*chaosSpec); err != nil {
+		fmt.Printf("ERROR: %s\n", err);
+		return;
+	}
+	if err = SetupTLS(*caFile, *insecure); err != nil {
+		fmt.Printf("ERROR: %s\n", err);
+		return;
+	}
+
+	// Parse a single document, nothing else is done
+	//
+	if parseInput != "" {
+		if err = ParseFilter(parseInput, *out, *week); err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+			os.Exit(1);
+		}
+		return;
+	}
+
+	// Pre-flight check of the site, nothing is published
+	//
+	if probe {
+		var thisWeek = *week;
+		if thisWeek == 0 {
+			_, thisWeek = WeekOf(time.LocalTime());
+		}
+		if !Probe(*url, weekdays, thisWeek, *year) {
+			os.Exit(1);
+		}
+		return;
+	}
+
+	// Regenerate archived weeks with the current parser and configuration,
+	// nothing is downloaded or published
+	//
+	if *reparse != "" {
+		since, err := ParseWeek(*reparse, *year);
+		if err == nil {
+			err = Reparse(*archive, since);
+		}
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+		}
+		return;
+	}
+
+	// Spread the start of runs scheduled at the same time
+	//
+	Jitter(*jitter);
+
+	// Rules of the site for robots, a disallowed day is never downloaded
+	// and the crawl delay is kept between all requests
+	//
+	var rules = new(Robots);
+	switch *robots {
+	case "fetch":
+		rules, err = FetchRobots(*url);
+	case "ignore":
+	default:
+		rules, err = ReadRobots(*robots);
+	}
+	if err != nil {
+		fmt.Printf("ERROR: Unable to read robots.txt: %s\n", err);
+		return;
+	}
+	requestDelay = int64(math.Fmax(*delay, rules.CrawlDelay) * 1e9);
+	fmt.Printf("robots.txt: %d disallowed paths, waiting %.1f seconds between requests\n", len(rules.Disallow), float64(requestDelay) / 1e9);
+
+	// Backfill of earlier weeks into the archive, nothing is published
+	//
+	if backfill != "" {
+		first, last, err := ParseWeekRange(backfill);
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+			return;
+		}
+		for w := first; w <= last; w++ {
+			if err = Backfill(*archive, w, providers, weekdays, dayNames, rules); err != nil {
+				log.Printf("ERROR: Backfill of week %d failed: %s\n", w, err);
+			}
+		}
+		return;
+	}
+
+	fmt.Printf("Downloading information for %s and week %i\n", *city, *week);
+
+	// Every run is recorded in the archive, also the failed ones
+	//
+	var run = StartRun(*city, *week);
+	if *archive != "" {
+		defer func() {
+			if err := run.Record(*archive); err != nil {
+				log.Println(err);
+			}
+		}();
+	}
+
+	// Earlier weeks from the archive, used for judging the parse
+	//
+	var history = make(map[int]*DataStruct);
+	if *archive != "" {
+		if weeks, err := ReadArchivedWeeks(*archive, *city); err == nil {
+			history = weeks;
+		}
+	}
+
+	var skeleton = &DataStruct{ SchemaVersion: SchemaVersion, Generator: VersionString(), City: *city, Year: *year, Week: *week };
+	if _, err = RunHook("pre_fetch", skeleton); err != nil {
+		fmt.Printf("ERROR: %s\n", err);
+		return;
+	}
+
+	var jsonData = FetchWeek(*week, providers, weekdays, dayNames, rules, history, *canteen);
+
+	// A refresh of today replaces today in the whole week from the
+	// archive, the published file may be trimmed
+	//
+	if *todayOnly {
+		archived, found := history[WeekKey(int64(*year), *week)];
+		if !found {
+			fmt.Printf("ERROR: No archived week %d-W%02d to refresh today in\n", *year, *week);
+			return;
+		}
+		published, err := CopyWeek(archived);
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err);
+			return;
+		}
+		var today = Today(*week, int64(*year));
+		ReportChanges(*archive, published, today, MergeDay(published, jsonData, today));
+		published.Year = jsonData.Year;
+		jsonData = published;
+	}
+
+	// The weather is only a hint, the menus are published without it
+	//
+	if *weather != "" {
+		if err = AddWeather(jsonData, *weather, int64(*year)); err != nil {
+			log.Println("WARNING: No weather forecast:", err);
+		}
+	}
+
+	// The days the restaurants usually have menus, from the archive
+	//
+	if *archive != "" {
+		AddTypicalDays(jsonData, history);
+	}
+
+	// Site specific changes of the parsed week
+	//
+	if jsonData, err = RunHook("post_parse", jsonData); err != nil {
+		fmt.Printf("ERROR: %s\n", err);
+		return;
+	}
+
+	// Metrics on the parsed data, written when the run is finished
+	//
+	run.SetDays(jsonData);
+	ParseMetrics(jsonData);
+	if *metricsFile != "" || *pushgateway != "" {
+		defer func() {
+			SetMetric("gauge", "lunchguiden_last_run_timestamp_seconds", "When the last run finished", CityLabels(*city), float64(time.Seconds()));
+			SetMetric("counter", "lunchguiden_fetched_bytes_total", "Bytes of the downloaded documents", CityLabels(*city), float64(bytesFetched));
+			if *metricsFile != "" {
+				if err := WriteMetrics(*metricsFile); err != nil {
+					log.Println(err);
+				}
+			}
+			if *pushgateway != "" {
+				if err := PushMetrics(*pushgateway, *city); err != nil {
+					log.Println("WARNING: Metrics not pushed:", err);
+				}
+			}
+		}();
+	}
+
+	// Alert about a week that looks very different from the previous
+	// one, which almost always means the scrape is broken
+	//
+	var anomalies = DetectAnomalies(jsonData, history[WeekKey(int64(*year), *week) - 1], *maxDrop);
+	for i := 0; i < len(anomalies); i++ {
+		Alert(*alertUrl, anomalies[i]);
+	}
+
+	// Look for changed logos before they are replaced by -embed-images
+	//
+	if *archive != "" && *logoChanges {
+		if err = DetectLogoChanges(*archive, jsonData); err != nil {
+			log.Println(err);
+		}
+	}
+	if *embedImages {
+		for day := 0; day < 5; day++ {
+			EmbedImages(jsonData.Days[day].Restaurants, *embedMax);
+			EmbedImages(jsonData.Days[day].Specials, *embedMax);
+		}
+	}
+
+	if !*noWarnings {
+		jsonData.Warnings = warnings;
+	}
+
+	// Generate the JSON code from the data structure, never publish
+	// data that doesn't match the schema. Only the published file is
+	// trimmed to the size budget, the archive keeps the whole week.
+	//
+	var outData []byte;
+	fullData, err := EncodeOutput(jsonData);
+	if err == nil {
+		outData, err = EncodeWithin(fullData, *maxBytes);
+	}
+	if err != nil {
+		log.Println("ERROR: Output not written, invalid data:", err);
+		run.Result = "invalid";
+		return;
+	}
+
+	// Compute the md5 hash value of the JSON data
+	//
+	var hashStr, hash = GenerateHash(outData);
+	fmt.Printf("MD5 is: %s\n", hashStr);
+	run.Hash = hashStr;
+	
+	// Suspicious results are held in the staging directory until
+	// they have been approved with "approve <file>"
+	//
+	if *staging != "" {
+		if hold, reason := NeedsReview(jsonData, history[WeekKey(int64(*year), *week)], *minConfidence, *maxDiff); hold {
+			fmt.Printf("Holding output for review, %s\n", reason);
+			run.Result = "held";
+			if err = Stage(*staging, *out, outData, fullData, hash); err != nil {
+				log.Println(err);
+			}
+			return;
+		}
+	}
+
+	// Write JSON data and its md5 hash to the output file
+	//
+	fmt.Printf("Writing %i bytes to %s\n", len(outData), *out);
+	if err = Publish(*out, outData, hash); err != nil {
+		log.Println(err);
+	} else {
+		run.Result = "published";
+		if *current != "" && *out != "-" {
+			if err = LinkCurrent(*current, *out); err != nil {
+				log.Println(err);
+			}
+		}
+		if _, err = RunHook("post_publish", jsonData); err != nil {
+			log.Println(err);
+		}
+	}
+
+	// The small card of today for widgets, the week as newline delimited
+	// JSON, the feed of the voice assistant and the text of the lobby
+	// display, from the published data
+	//
+	if *todayCard != "" {
+		if err = WriteTodayCard(*todayCard, jsonData, int64(*year), *cardN, strings.Split(*favorites, ",", -1)); err != nil {
+			log.Println(err);
+		}
+	}
+
+	if *ndjson != "" {
+		if err = WriteNDJSON(*ndjson, jsonData); err != nil {
+			log.Println(err);
+		}
+	}
+	if *voiceFeed != "" {
+		if err = WriteVoiceFeed(*voiceFeed, jsonData); err != nil {
+			log.Println(err);
+		}
+	}
+	if *kiosk != "" {
+		var columns, rows, _ = ParseKioskSize(*kioskSize);
+		if err = WriteKiosk(*kiosk, jsonData, int64(*year), columns, rows); err != nil {
+			log.Println(err);
+		}
+	}
+
+	// Keep the published data in the archive for comparisons over weeks
+	//
+	if *archive != "" {
+		if err = ArchiveOutput(*archive, *city, *year, *week, fullData); err != nil {
+			log.Println(err);
+		}
+	}
+
+	// Report restaurants that seem to have disappeared from Lunchguiden,
+	// so that the image table and favorites can be cleaned up
+	//
+	if *archive != "" && *deadWeeks > 0 {
+		weeks, err := ReadArchivedWeeks(*archive, *city);
+		if err != nil {
+			log.Println(err);
+			return;
+		}
+		var report = DeadRestaurants(weeks, WeekKey(int64(*year), *week), *deadWeeks);
+		fmt.Printf("%d restaurants not seen for %d weeks\n", len(report), *deadWeeks);
+		for i := 0; i < len(report); i++ {
+			fmt.Printf("  %s\n", report[i]);
+		}
+	}
+}
+
+// Downloads and parses all days of a week from the providers. The
+// restaurants of later providers are added to those of the first one,
+// leaving out the ones it already has.
+//
+func FetchWeek(week int, providers []string, weekdays []string, dayNames []string, rules *Robots, history map[int]*DataStruct, canteenFile string) *DataStruct {
+	var jsonData = fetchProvider(week, providers[0], weekdays, dayNames, rules, history);
+	for i := 1; i < len(providers); i++ {
+		fmt.Printf("Merging restaurants from %s\n", providers[i]);
+		MergeWeeks(jsonData, fetchProvider(week, providers[i], weekdays, dayNames, rules, history));
+	}
+
+	// Menus of the internal canteens, maintained by hand, are counted in
+	// the summary and marked as weekly menus like the others
+	//
+	if canteenFile != "" {
+		if err := MergeCanteens(jsonData, canteenFile); err != nil {
+			log.Println("WARNING: Canteens not added:", err);
+		}
+	}
+
+	jsonData.Summary = Summarize(jsonData);
+	MarkWeeklyMenus(jsonData, *dedupWeekly);
+	return jsonData;
+}
+
+// Downloads and parses all days of a week from one provider
+//
+func fetchProvider(week int, name string, weekdays []string, dayNames []string, rules *Robots, history map[int]*DataStruct) *DataStruct {
+	var provider = config.Providers[name];
+	var (
+		err os.Error;
+		inData []byte;
+	)
 
 	// Beginning of the JSON data structure creation with 
 	// basic information about this particular menu
 	//	
 	jsonData := new(DataStruct);
+	jsonData.SchemaVersion = SchemaVersion;
+	jsonData.Generator = VersionString();
 	jsonData.City = *city;
-	jsonData.Week = *week;
-	
-	fmt.Printf("Downloading information for %s and week %i\n", *city, *week);
+	jsonData.Year = *year;
+	jsonData.Week = week;
+
+	// Failed downloads by error class
+	//
+	var failures = make(map[string]int);
+
+	// Deadline of the whole run, so one hanging download can't delay
+	// publishing past lunch
+	//
+	var deadline = time.Nanoseconds() + int64(*timeout) * 1e9;
 
 	// Iterates all weekdays
 	//
 	for day := 0; day < 5; day++ {
+		jsonData.Days[day].Day 	= day;
+		jsonData.Days[day].Name = dayNames[day];
+		jsonData.Days[day].Date = DayDate(int64(*year), week, day);
+
+		if *todayOnly && day != Today(week, int64(*year)) {
+			continue;
+		}
+
+		// Public holidays are marked in the output, and if asked to
+		// nothing is downloaded since the menus will be empty anyway
+		//
+		if *holidays != "ignore" {
+			var holiday = HolidayName(WeekdayDate(int64(*year), week, day));
+			jsonData.Days[day].Holiday = holiday;
+
+			if holiday != "" && *holidays == "skip" {
+				fmt.Printf("Skipping %s, %s\n", dayNames[day], holiday);
+				continue;
+			}
+		}
 	
+		// The time budget of the day is limited by what's left of the
+		// budget of the whole run
+		//
+		var budget = int64(*dayTimeout) * 1e9;
+		if *timeout > 0 {
+			var left = deadline - time.Nanoseconds();
+			if left <= 0 {
+				left = 1;
+			}
+			if budget <= 0 || left < budget {
+				budget = left;
+			}
+		}
+
 		// Downloads the current menu from the web
 		// NOTE: week variable in URL must be provided from the input
+		//	 unless the provider has {week} in its templates
 		//
-		res, _, err = http.Get(fmt.Sprintf("%s&veckodag=%s", *url, weekdays[day]));
-	
-		// No error, continue reading HTML data into the inData variable
-		//
-		if err == nil {
-			fmt.Printf("OK, response is %s length is %i\n", res.Status, res.ContentLength);
-			inData, err = ioutil.ReadAll(res.Body);
-			res.Body.Close();
+		var request = provider.Request(map[string]string {
+			"url":	*url,
+			"day":	provider.Day(day, weekdays),
+			"week":	fmt.Sprint(week),
+			"year":	fmt.Sprint(*year),
+		});
+		if rules.Allowed(RequestPath(request.Url)) {
+			inData, err = FetchWithin(request, budget);
+
+			// The site sometimes sends truncated documents when it's
+			// busy, they are downloaded once more after a while
+			//
+			if err == nil && Suspicious(inData, *minSize, *marker) != "" {
+				log.Printf("WARNING: %s looks truncated (%s), downloading it again in %.0f seconds\n", dayNames[day], Suspicious(inData, *minSize, *marker), *refetchDelay);
+				time.Sleep(int64(*refetchDelay * 1e9));
+				inData, err = FetchWithin(request, budget);
+				if reason := Suspicious(inData, *minSize, *marker); err == nil && reason != "" {
+					err = &TruncatedError{ request.Url, reason };
+				}
+			}
 		} else {
-			log.Println(err);
+			err = os.NewError(request.Url + " is disallowed by robots.txt");
+		}
+
+		// Failures are marked on the day and counted by class for the
+		// summary and the metrics
+		//
+		if err != nil {
+			var class = ClassifyError(err);
+			jsonData.Days[day].Error = class;
+			log.Printf("ERROR: Download of %s failed (%s): %s\n", dayNames[day], class, err);
+			failures[class]++;
+			AddMetric("lunchguiden_fetch_errors_total", "Failed downloads by error class", CityLabels(*city, "class", class), 1);
 		}
 						
 		// No error reading data from the HTML document, continue building the 
 		// JSON data structure with current day and parse the HTML data
 		// 
 		if err == nil {
-			jsonData.Days[day].Day 		= day;
-			jsonData.Days[day].Name 	= weekdays[day];
-			jsonData.Days[day].Restaurants 	= Parse(inData);
-		} else {
-			log.Println(err);
+			ParseDay(&jsonData.Days[day], name, int64(*year), week, inData, history);
+		}
+
+		// Keep a copy of the HTML document the day was parsed from and
+		// reference it by hash, so the output can be reproduced later on
+		//
+		if err == nil && *archive != "" {
+			jsonData.Days[day].Snapshot, err = ArchiveSnapshot(*archive, inData);
+			if err != nil {
+				log.Println(err);
+			}
 		}
 	}
 
-	// Generate the JSON code from the data structure
+	// Summary of the downloads
 	//
+	if len(failures) == 0 {
+		fmt.Printf("Summary: all days downloaded\n");
+	} else {
+		fmt.Printf("Summary: download failures");
+		for class, count := range failures {
+			fmt.Printf(", %s: %d", class, count);
+		}
+		fmt.Printf("\n");
+	}
+	return jsonData;
+}
+
+// Parses the HTML document of a day from the source into the day of the
+// JSON data structure
+//
+func ParseDay(dayData *DayData, source string, year int64, week int, inData []byte, history map[int]*DataStruct) {
+	var day = dayData.Day;
+
+	if *debugDump != "" {
+		if err := DumpFragments(*debugDump, *city, week, day, inData); err != nil {
+			log.Println(err);
+		}
+	}
+	dayData.Restaurants, dayData.Parser = Parse(inData);
+	ResolveImages(dayData.Restaurants, config.Providers[source].ImageBase, config.ImageHosts, day);
+	LinkIds(dayData.Restaurants, day, history);
+	dayData.Restaurants = MergeDuplicates(dayData.Restaurants);
+	for i := 0; i < len(dayData.Restaurants); i++ {
+		dayData.Restaurants[i].Source = source;
+	}
+	dayData.Restaurants = PrepareRestaurants(dayData.Restaurants);
+	dayData.Confidence = Confidence(dayData.Restaurants, HistoricalAverage(history, WeekKey(year, week), day));
+	CheckDay(day, inData, dayData.Restaurants);
+
+	if *specials {
+		SplitSpecials(dayData);
+	}
+	OrderRestaurants(dayData.Restaurants, *order, strings.Split(*favorites, ",", -1));
+
+	if *checkImages != "" {
+		CheckImages(dayData.Restaurants, *checkImages, *placeholder);
+		CheckImages(dayData.Specials, *checkImages, *placeholder);
+	}
+}
+
+// Applies the overrides of the configuration, the distances and the text
+// profile and limits to restaurants of a day, wherever they came from
+//
+func PrepareRestaurants(list []RestData) []RestData {
+	list = ApplyOverrides(list, config.Restaurants);
+	if office != nil {
+		AddDistances(list, office, *osrm);
+	}
+	SanitizeRestaurants(list, *textProfile);
+	TruncateRestaurants(list, *maxMenu, *maxDescription);
+	return list;
+}
+
+// Generates the JSON code from the data structure, with the active
+// content removed from the texts, the hashes of the cards set and
+// validated against the schema unless turned off
+//
+func EncodeOutput(jsonData *DataStruct) ([]byte, os.Error) {
+	SanitizeWeek(jsonData);
+	HashWeek(jsonData);
+
 	var output        = bytes.NewBuffer(make([]byte, 0));
 	var jsonOutput, _ = json.Marshal(jsonData);
 	json.Indent(output, jsonOutput, "", "");		// Indent with no prefix
@@ -159,94 +1133,137 @@ func main() {
 	// Convert the JSON data from *Buffer to a []byte slice 
 	//
 	var outData = make([]byte, output.Len());
-	var n,_ = output.Read(outData);
+	output.Read(outData);
 
-	// Compute the md5 hash value of the JSON data
-	//
-	var hashStr, hash = GenerateHash(outData);
-	fmt.Printf("MD5 is: %s\n", hashStr);
-	
-	// Write JSON data to output file
-	//
-	fmt.Printf("Writing %i bytes to %s\n", n, *out);
-	err = ioutil.WriteFile(*out, outData, 0644);
-	
-	if err != nil {
-		log.Println(err);
-	}
-	
-	// Write MD5 hash to file
-	//
-	fmt.Printf("Writing md5 sum\n");
-	err = ioutil.WriteFile(fmt.Sprintf("%s.md5", *out), hash, 0644);
-	if err != nil {
-		log.Println(err);
+	if *validateOutput {
+		if err := ValidateOutput(outData); err != nil {
+			return nil, err;
+		}
 	}
+	return outData, nil;
 }
 
-// Function for parsing out the real information from the HTML document
+// Regular expressions used by the parser
+//
+var rx_image = regexp.MustCompile("SRC=\"([^\"]+)\"");
+var rx_text  = regexp.MustCompile("<center>(.+)</center>");
+var rx_html  = regexp.MustCompile("<[^>]+>");
+
+// Function for parsing out the real information from the HTML document,
+// the original string splitting parser
 //
-func Parse(in []byte) ([]RestData) {
-	// Setup a string to work with and some regular expressions
+func ParseLegacy(in string) ([]RestData) {
+	// The HTML document is split into one section per restaurant
 	// 
-	var strData  = string(in);
-	var rx_image = regexp.MustCompile("SRC=\"([^\"]+)\"");
-	var rx_text  = regexp.MustCompile("<center>(.+)</center>");
-	var rx_html  = regexp.MustCompile("<[^>]+>");
-	
-	// The HTML document is split into sections of one resturant per
-	// item in the tds slice. 
-	//
-	var tds = strings.Split(strData, "<TD WIDTH=\"130\" ALIGN=\"CENTER\" VALIGN=\"TOP\" BGCOLOR=\"#FFFFFF\">", -1);
+	var tds = SplitRestaurants(in);
 
 	// Create RestData slice to add restaurant data to, length is computed
 	// from the number of items in the tds slice.
-	// NOTE: Minus one is needed because tds[0] represent the data _before_
-	//	 The first restaurant entry appears.
 	//
-	var restaurant = make([]RestData, (len(tds) - 1));
+	var restaurant = make([]RestData, len(tds));
 
 	// Iterate all restaurants from the HTML document
 	//
-	for i := 1; i < len(tds); i++ {
+	for i := 0; i < len(tds); i++ {
+		restaurant[i] = ParseRestaurant(tds[i]);
+	}
+	return restaurant;
+}
 
-		// Various regular expressions and splits in order to parse out
-		// the important information from all the junky HTML parts.
-		//
-		var image 	= rx_image.FindAllStringSubmatch(tds[i], -1);
-		var name	= MatchRestaurant(image[0][1]);
-		var tmpText 	= rx_text.FindAllString(tds[i], -1);
-		var tmpMenu0  	= strings.Split(tds[i], "<TD WIDTH=\"311\" VALIGN=\"TOP\" BGCOLOR=\"#FFFFFF\"><IMG SRC=\"../grafik/space.gif\" BORDER=0 width=\"1\" HEIGHT=\"5\">", -1);
-		var tmpMenu1 	= strings.Split(tmpMenu0[1], "</TD>", -1);
-		var menu 	= strings.Replace(tmpMenu1[0], "<LI>", "* ", -1);
-				
-		// Replace all <br> and <br/> tags with newlines instead (\n)
-		//
-		menu = strings.Replace(menu, "<BR>", "\n", -1);
-		menu = strings.Replace(menu, "<br/>", "\n", -1);
-		
-		// Compute real index and add restaurant data to the RestData slice
-		// 
-		var index = i - 1;
+// Function for splitting the HTML document into the HTML fragments of
+// the restaurants
+//
+func SplitRestaurants(strData string) []string {
+	var tds = strings.Split(strData, "<TD WIDTH=\"130\" ALIGN=\"CENTER\" VALIGN=\"TOP\" BGCOLOR=\"#FFFFFF\">", -1);
+
+	// NOTE: tds[0] represent the data _before_ the first restaurant
+	//	 entry appears.
+	//
+	return tds[1:];
+}
+
+// Function for parsing the HTML fragment of one restaurant
+//
+func ParseRestaurant(td string) RestData {
+	var restaurant RestData;
+
+	// Various regular expressions and splits in order to parse out
+	// the important information from all the junky HTML parts.
+	//
+	var image 	= rx_image.FindAllStringSubmatch(td, -1);
+	var tmpText 	= rx_text.FindAllString(td, -1);
+	var tmpMenu0  	= strings.Split(td, "<TD WIDTH=\"311\" VALIGN=\"TOP\" BGCOLOR=\"#FFFFFF\"><IMG SRC=\"../grafik/space.gif\" BORDER=0 width=\"1\" HEIGHT=\"5\">", -1);
+	var tmpMenu1 	= strings.Split(tmpMenu0[1], "</TD>", -1);
+	var menu 	= strings.Replace(tmpMenu1[0], "<LI>", "* ", -1);
+			
+	// Replace all <br> and <br/> tags with newlines instead (\n)
+	//
+	menu = strings.Replace(menu, "<BR>", "\n", -1);
+	menu = strings.Replace(menu, "<br/>", "\n", -1);
 	
-		restaurant[index].ImageUrl 	= fmt.Sprintf("http://service.dt.se/lunch/%s", image[1]);
-		restaurant[index].Name 		= name;
-		restaurant[index].Menu 		= strings.TrimSpace(menu);
-		
-		// If a "subtext" or description is found (the short text beneath
-		// the image in the menu). Parse out all HTML from it and save it 
-		// to the RestData slice
-		//
-		if len(tmpText) > 0 {
-			var text = rx_html.ReplaceAllString(tmpText[0], " ");
-			restaurant[index].Description = text;
-		} else {
-			restaurant[index].Description = "";
-		}
+	restaurant.Menu = strings.TrimSpace(menu);
+	
+	// If a "subtext" or description is found (the short text beneath
+	// the image in the menu). Parse out all HTML from it and save it 
+	// to the RestData struct
+	//
+	if len(tmpText) > 0 {
+		var text = rx_html.ReplaceAllString(tmpText[0], " ");
+		restaurant.Description = text;
+	} else {
+		restaurant.Description = "";
 	}
+
+	CompleteRestaurant(&restaurant, image[0][1]);
 	return restaurant;
 }
 
+// Function for filling in everything that's derived from the logo, menu
+// and description of a restaurant, shared by all parser strategies
+//
+func CompleteRestaurant(restaurant *RestData, image string) {
+	restaurant.ImageUrl 	= image;
+	restaurant.Name 	= MatchRestaurant(image);
+	restaurant.Id 		= RestaurantId(restaurant.Name, image);
+	CompleteText(restaurant);
+}
+
+// Function for filling in everything that's derived from the menu and
+// description of a restaurant, also used for the restaurants that
+// don't come from Lunchguiden
+//
+func CompleteText(restaurant *RestData) {
+	// Flag seasonal menus like julbord so they can be highlighted
+	//
+	restaurant.Special = DetectSpecial(restaurant.Menu + "\n" + restaurant.Description);
+
+	// Serving hours and contact details are usually given in the description
+	//
+	if Enabled("serving_hours") {
+		restaurant.ServingHours = ExtractHours(restaurant.Description + "\n" + restaurant.Menu);
+	}
+	if Enabled("contact_extraction") {
+		restaurant.Phone = ExtractPhone(restaurant.Description);
+		restaurant.Website = ExtractWebsite(restaurant.Description);
+	}
+
+	// Closed restaurants keep the closure note but have no dishes
+	//
+	if Enabled("closed_detection") {
+		restaurant.Closed, restaurant.ClosedNote = DetectClosed(restaurant.Menu, restaurant.Description);
+	}
+	if restaurant.Closed {
+		restaurant.Dishes = make([]DishData, 0);
+		return;
+	}
+
+	// Split the menu into classified dishes for filtering in clients
+	//
+	if Enabled("dish_splitting") {
+		restaurant.Dishes = SplitDishes(restaurant.Menu);
+	}
+}
+
 // Function for trying to determine the name of the current restaurants
 // (Since that information isn't avalible on the web, only in the images)
 //
@@ -376,6 +1393,31 @@ func MatchRestaurant(in string) string {
 	return "";
 }
 
+// Expands the placeholders of an output path for the city and week of
+// the run. The week is written with two digits so the directories sort.
+//
+func OutputPath(template string) string {
+	return ExpandTemplate(template, map[string]string {
+		"city":	Slug(*city),
+		"year":	fmt.Sprint(*year),
+		"week":	fmt.Sprintf("%02d", *week),
+	});
+}
+
+// Function for writing the JSON data and the md5 hash to the output file
+//
+func Publish(out string, outData []byte, hash []byte) os.Error {
+	var err = WriteOutput(out, outData);
+	if err != nil || out == "-" {
+		return err;
+	}
+	
+	// Write MD5 hash to file
+	//
+	fmt.Printf("Writing md5 sum\n");
+	return WriteFile(fmt.Sprintf("%s.md5", out), hash);
+}
+
 // Simple function for generating the md5 hash of the input
 // data both as a string and a []byte value. 
 //
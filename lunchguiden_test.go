@@ -0,0 +1,84 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Tests of the round trip of the output through JSON.
+*/
+
+package main
+
+import (
+	"bytes"
+	"json"
+	"rand"
+	"testing"
+)
+
+// Pieces of text the random weeks are made of, with the characters and
+// markup found on the site
+//
+var roundTripTexts = []string {
+	"Pannbiff med lök", "Räksmörgås 95 kr", "Fisk &amp; chips", "Dagens <b>soppa</b>",
+	"Köttbullar\nmed potatismos", "Café \"Ester\"", "85/95 kr", "Åsen", "",
+};
+
+// Returns a random text from the pieces
+//
+func roundTripText(r *rand.Rand) string {
+	return roundTripTexts[r.Intn(len(roundTripTexts))];
+}
+
+// Returns a random week that matches the schema
+//
+func randomWeek(r *rand.Rand) *DataStruct {
+	var data = &DataStruct{ SchemaVersion: SchemaVersion, Generator: "test", City: "Falun", Year: 2011, Week: 1 + r.Intn(53) };
+	for day := 0; day < 5; day++ {
+		var d = &data.Days[day];
+		d.Day = day;
+		d.Name = weekdayNames["sv"][day];
+		d.Confidence = float64(r.Intn(5)) / 4;
+		for i := r.Intn(4); i > 0; i-- {
+			var rest = RestData{ Id: string('a' + r.Intn(26)), Name: roundTripText(r), Description: roundTripText(r), Menu: roundTripText(r) };
+			rest.WeeklyMenu = r.Intn(2) == 0;
+			for j := r.Intn(3); j > 0; j-- {
+				var dish = DishData{ Text: "Dagens " + roundTripText(r), Price: "85 kr", PriceOre: []int { 100 * r.Intn(150) } };
+				dish.Categories = []string { "vegetarian" };
+				rest.Dishes = append(rest.Dishes, dish);
+			}
+			d.Restaurants = append(d.Restaurants, rest);
+		}
+	}
+	return data;
+}
+
+func TestOutputRoundTrip(t *testing.T) {
+	var r = rand.New(rand.NewSource(1));
+
+	for i := 0; i < 200; i++ {
+		var week = randomWeek(r);
+		first, err := EncodeOutput(week);
+		if err != nil {
+			t.Fatalf("week %d not encoded: %s", i, err);
+		}
+
+		var decoded = new(DataStruct);
+		if err = json.Unmarshal(first, decoded); err != nil {
+			t.Fatalf("week %d not decoded: %s", i, err);
+		}
+		second, err := EncodeOutput(decoded);
+		if err != nil {
+			t.Fatalf("decoded week %d not encoded: %s", i, err);
+		}
+		if !bytes.Equal(first, second) {
+			t.Fatalf("week %d changed in the round trip:\n%s\n%s", i, first, second);
+		}
+
+		var firstHash, _ = GenerateHash(first);
+		var secondHash, _ = GenerateHash(second);
+		if firstHash != secondHash {
+			t.Fatalf("md5 of week %d changed in the round trip", i);
+		}
+	}
+}
@@ -0,0 +1,70 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Merging of the weeks of several providers covering the same city into
+    one, in order of precedence, leaving out the restaurants already
+    found by an earlier provider.
+*/
+
+package main
+
+import (
+	"strings"
+)
+
+// Adds the restaurants of the other week that the week doesn't have.
+// A day that failed is replaced by the day of the other week if that
+// one didn't.
+//
+func MergeWeeks(data *DataStruct, other *DataStruct) {
+	for day := 0; day < 5; day++ {
+		var dayData = &data.Days[day];
+		var otherDay = &other.Days[day];
+
+		if dayData.Error != "" && otherDay.Error == "" {
+			*dayData = *otherDay;
+			continue;
+		}
+
+		var restaurants = mergeRestaurants(dayData.Restaurants, otherDay.Restaurants, dayData);
+		var specials = mergeRestaurants(dayData.Specials, otherDay.Specials, dayData);
+		dayData.Restaurants, dayData.Specials = restaurants, specials;
+		OrderRestaurants(dayData.Restaurants, *order, strings.Split(*favorites, ",", -1));
+	}
+}
+
+// Returns the list of the day with the restaurants not already on the
+// day added
+//
+func mergeRestaurants(result []RestData, list []RestData, dayData *DayData) []RestData {
+	for i := 0; i < len(list); i++ {
+		if !hasRestaurant(dayData.Restaurants, &list[i]) && !hasRestaurant(dayData.Specials, &list[i]) {
+			result = append(result, list[i]);
+		}
+	}
+	return result;
+}
+
+// Tells if the restaurant is in the list
+//
+func hasRestaurant(list []RestData, r *RestData) bool {
+	for i := 0; i < len(list); i++ {
+		if SameRestaurant(&list[i], r) {
+			return true;
+		}
+	}
+	return false;
+}
+
+// Tells if two restaurants from different providers are the same one,
+// by id or by a very similar name
+//
+func SameRestaurant(a *RestData, b *RestData) bool {
+	if a.Id == b.Id {
+		return true;
+	}
+	return a.Name != "" && b.Name != "" && Similarity(a.Name, b.Name) >= matchThreshold;
+}
@@ -0,0 +1,164 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Metrics of a run, written in the Prometheus text format with -metrics
+    so they can be picked up by the textfile collector of node_exporter,
+    or pushed to a Pushgateway with -pushgateway when there is no
+    node_exporter on the host.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"http"
+	"os"
+	"strings"
+)
+
+type Metric struct {
+	Name string;
+	Help string;
+	Type string;			// "gauge" or "counter"
+	Labels []string;		// Label sets, like `city="Falun"`
+	Values []float64;
+}
+
+// All metrics of the run, in the order they were first set
+//
+var metrics = make([]*Metric, 0);
+
+// Sets the value of a metric for a label set, the metric is created if
+// it doesn't exist
+//
+func SetMetric(kind string, name string, help string, labels string, value float64) {
+	var m = findMetric(kind, name, help);
+	for i := 0; i < len(m.Labels); i++ {
+		if m.Labels[i] == labels {
+			m.Values[i] = value;
+			return;
+		}
+	}
+	m.Labels = append(m.Labels, labels);
+	m.Values = append(m.Values, value);
+}
+
+// Adds to the value of a counter for a label set
+//
+func AddMetric(name string, help string, labels string, value float64) {
+	var m = findMetric("counter", name, help);
+	for i := 0; i < len(m.Labels); i++ {
+		if m.Labels[i] == labels {
+			m.Values[i] += value;
+			return;
+		}
+	}
+	m.Labels = append(m.Labels, labels);
+	m.Values = append(m.Values, value);
+}
+
+func findMetric(kind string, name string, help string) *Metric {
+	for i := 0; i < len(metrics); i++ {
+		if metrics[i].Name == name {
+			return metrics[i];
+		}
+	}
+	var m = &Metric{ Name: name, Help: help, Type: kind };
+	metrics = append(metrics, m);
+	return m;
+}
+
+// Returns the label set for a city, and optionally more label pairs
+//
+func CityLabels(city string, more ...string) string {
+	var labels = fmt.Sprintf("city=%q", city);
+	for i := 0; i + 1 < len(more); i += 2 {
+		labels += fmt.Sprintf(",%s=%q", more[i], more[i + 1]);
+	}
+	return labels;
+}
+
+// Sets the metrics of the parsed restaurants of a week: the number of
+// restaurants, the number of unknown logos and the percentage of the
+// restaurants that could be matched to a name
+//
+func ParseMetrics(data *DataStruct) {
+	var total, unknown = 0, 0;
+
+	for day := 0; day < 5; day++ {
+		var list = append(data.Days[day].Restaurants, data.Days[day].Specials...);
+		for i := 0; i < len(list); i++ {
+			total++;
+			if list[i].Name == "" {
+				unknown++;
+			}
+		}
+		SetMetric("gauge", "lunchguiden_parse_confidence", "Confidence score of the parse of a day",
+			CityLabels(data.City, "day", fmt.Sprint(day)), data.Days[day].Confidence);
+	}
+
+	var coverage = 100.0;
+	if total > 0 {
+		coverage = 100 * float64(total - unknown) / float64(total);
+	}
+
+	var labels = CityLabels(data.City);
+	SetMetric("gauge", "lunchguiden_restaurants_parsed", "Restaurants parsed for the week", labels, float64(total));
+	SetMetric("gauge", "lunchguiden_unknown_logos", "Restaurants with a logo missing from the image table", labels, float64(unknown));
+	SetMetric("gauge", "lunchguiden_mapping_coverage_percent", "Percentage of restaurants matched to a name", labels, coverage);
+}
+
+// Returns all metrics in the Prometheus text format
+//
+func FormatMetrics() []byte {
+	var buf = bytes.NewBuffer(make([]byte, 0));
+
+	for _, m := range metrics {
+		fmt.Fprintf(buf, "# HELP %s %s\n", m.Name, m.Help);
+		fmt.Fprintf(buf, "# TYPE %s %s\n", m.Name, m.Type);
+		for i := 0; i < len(m.Labels); i++ {
+			fmt.Fprintf(buf, "%s{%s} %v\n", m.Name, m.Labels[i], m.Values[i]);
+		}
+	}
+	return buf.Bytes();
+}
+
+// Writes the metrics to the file. A temporary file is renamed in place
+// so that a collector never reads a half written file.
+//
+func WriteMetrics(path string) os.Error {
+	if err := WriteFile(path + ".tmp", FormatMetrics()); err != nil {
+		return err;
+	}
+	return os.Rename(path + ".tmp", path);
+}
+
+// Pushes the metrics to a Pushgateway, grouped by job and city. The
+// metrics of the previous push of the city are replaced. The city is
+// given in base64, the way the Pushgateway takes label values that may
+// hold a / or other characters with a meaning in the path.
+//
+func PushMetrics(gateway string, city string) os.Error {
+	var encoded = make([]byte, base64.URLEncoding.EncodedLen(len(city)));
+	base64.URLEncoding.Encode(encoded, []byte(city));
+	if len(encoded) == 0 {
+		encoded = []byte("=");
+	}
+
+	var pushUrl = fmt.Sprintf("%s/metrics/job/lunchguiden/city@base64/%s", strings.TrimRight(gateway, "/"), encoded);
+	res, err := Do(&Request{ Method: "POST", Url: pushUrl, Body: string(FormatMetrics()), ContentType: "text/plain; version=0.0.4" });
+	if err != nil {
+		return err;
+	}
+	defer res.Body.Close();
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusAccepted {
+		return &StatusError{ pushUrl, res.Status };
+	}
+	return nil;
+}
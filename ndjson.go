@@ -0,0 +1,86 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    The week as newline delimited JSON (-ndjson): for every day a line
+    with the fields of the day, also for days without restaurants,
+    followed by a line per restaurant on the day, sorted by id. The
+    fields of a line are in the order of the structs below, which doesn't
+    change between runs. A change in one menu changes one line, so rsync
+    and git only move that line when mirroring.
+*/
+
+package main
+
+import (
+	"bytes"
+	"json"
+	"os"
+	"sort"
+	"strings"
+)
+
+type WeekDay struct {
+	Type string;			// "day"
+	City string;
+	Year int;
+	Week int;
+	Day int;
+	Date string;
+	Name string;
+	Holiday string;
+	Error string;
+	Weather *Weather;
+}
+type RestaurantDay struct {
+	Type string;			// "restaurant"
+	City string;
+	Year int;
+	Week int;
+	Day int;
+	Date string;
+	Special bool;			// From the specials of the day
+	Restaurant RestData;
+}
+
+// Writes the days and restaurants of the week as newline delimited JSON
+//
+func WriteNDJSON(file string, data *DataStruct) os.Error {
+	var buf = bytes.NewBuffer(make([]byte, 0));
+
+	for day := 0; day < 5; day++ {
+		var d = &data.Days[day];
+		line, err := json.Marshal(WeekDay{ "day", data.City, data.Year, data.Week, day, d.Date, d.Name, d.Holiday, d.Error, d.Weather });
+		if err != nil {
+			return err;
+		}
+		buf.Write(line);
+		buf.WriteString("\n");
+
+		var lines = make([]string, 0);
+		for _, special := range []bool{ false, true } {
+			var list = d.Restaurants;
+			if special {
+				list = d.Specials;
+			}
+			for i := 0; i < len(list); i++ {
+				line, err := json.Marshal(RestaurantDay{ "restaurant", data.City, data.Year, data.Week, day, d.Date, special, list[i] });
+				if err != nil {
+					return err;
+				}
+				lines = append(lines, list[i].Id + "\x00" + string(line));
+			}
+		}
+
+		// Sorted by id, which is in front of every line
+		//
+		sort.SortStrings(lines);
+		for _, line := range lines {
+			buf.WriteString(line[strings.Index(line, "\x00") + 1:]);
+			buf.WriteString("\n");
+		}
+	}
+	return WriteFile(file, buf.Bytes());
+}
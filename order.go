@@ -0,0 +1,75 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Ordering of the restaurants in the output. All orderings are stable so
+    that equal restaurants keep their source order and the md5 hash of the
+    output doesn't change between runs for the same data.
+*/
+
+package main
+
+import (
+	"strings"
+)
+
+// Sorts the restaurants in place with the given strategy: "source" keeps
+// the order of Lunchguiden, "alpha" sorts by name, "favorites" puts
+// the restaurants given with -favorites first, in the order listed, and
+// "distance" puts the closest first and those without a distance last.
+//
+func OrderRestaurants(list []RestData, order string, favorites []string) {
+	switch order {
+	case "alpha":
+		stableSort(list, func(a, b *RestData) bool {
+			return collationKey(a.Name) < collationKey(b.Name);
+		});
+	case "favorites":
+		stableSort(list, func(a, b *RestData) bool {
+			return favoriteRank(a.Id, favorites) < favoriteRank(b.Id, favorites);
+		});
+	case "distance":
+		stableSort(list, func(a, b *RestData) bool {
+			return b.DistanceMeters == 0 && a.DistanceMeters > 0 ||
+				a.DistanceMeters > 0 && a.DistanceMeters < b.DistanceMeters;
+		});
+	}
+}
+
+// Insertion sort, which is stable and more than fast enough for the
+// few dozen restaurants of a day
+//
+func stableSort(list []RestData, less func(a, b *RestData) bool) {
+	for i := 1; i < len(list); i++ {
+		for j := i; j > 0 && less(&list[j], &list[j - 1]); j-- {
+			list[j], list[j - 1] = list[j - 1], list[j];
+		}
+	}
+}
+
+// Returns the position of the restaurant in the favorites list, all other
+// restaurants are placed after the favorites
+//
+func favoriteRank(id string, favorites []string) int {
+	for i := 0; i < len(favorites); i++ {
+		if favorites[i] == id {
+			return i;
+		}
+	}
+	return len(favorites);
+}
+
+// Returns a key for comparing names in Swedish alphabetical order, where
+// å, ä and ö come after z and é is an e. The three are mapped above
+// every ASCII letter, so "Åkes" comes after "Zorbas" and not just "Z".
+//
+func collationKey(name string) string {
+	var key = strings.ToLower(DecodeEntities(name));
+	key = strings.Replace(key, "å", "\x7f\x01", -1);
+	key = strings.Replace(key, "ä", "\x7f\x02", -1);
+	key = strings.Replace(key, "ö", "\x7f\x03", -1);
+	key = strings.Replace(key, "é", "e", -1);
+	return key;
+}
@@ -0,0 +1,40 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Tests of the ordering of the restaurants.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestOrderAlpha(t *testing.T) {
+	var names = []string { "Örat", "Åkes", "Zorbas", "Ängen", "Z", "Café Ester", "Aalto", "&Aring;sen" };
+	var want = []string { "Aalto", "Café Ester", "Z", "Zorbas", "Åkes", "&Aring;sen", "Ängen", "Örat" };
+
+	var list = make([]RestData, len(names));
+	for i := 0; i < len(names); i++ {
+		list[i].Name = names[i];
+	}
+	OrderRestaurants(list, "alpha", nil);
+
+	for i := 0; i < len(want); i++ {
+		if list[i].Name != want[i] {
+			t.Fatalf("position %d is %q, want %q", i, list[i].Name, want[i]);
+		}
+	}
+}
+
+func TestOrderAlphaIsStable(t *testing.T) {
+	var list = []RestData{ RestData{ Id: "a", Name: "Zeta" }, RestData{ Id: "b", Name: "zeta" }, RestData{ Id: "c", Name: "Åre" } };
+	OrderRestaurants(list, "alpha", nil);
+
+	if list[0].Id != "a" || list[1].Id != "b" || list[2].Id != "c" {
+		t.Errorf("order is %s %s %s, want a b c", list[0].Id, list[1].Id, list[2].Id);
+	}
+}
@@ -0,0 +1,277 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    The parser is a chain of strategies that are tried in order until one
+    of them gives a plausible result, so that small changes in the markup
+    of Lunchguiden don't break the output completely:
+
+      tokenizer  walks the tags of the document, doesn't care about the
+                 order or case of the attributes
+      legacy     the original string splitting parser
+      heuristic  takes the text between the logos, last resort
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type Strategy struct {
+	Name string;
+	Parse func(in string) []RestData;
+}
+
+var strategies = []Strategy {
+	Strategy{ "tokenizer", ParseTokens },
+	Strategy{ "legacy", ParseLegacy },
+	Strategy{ "heuristic", ParseHeuristic },
+};
+
+// Parses the HTML document with the first strategy giving a plausible
+// result and returns the restaurants along with the name of the strategy
+//
+func Parse(in []byte) ([]RestData, string) {
+	var strData = string(in);
+
+	for i := 0; i < len(strategies); i++ {
+		if strategies[i].Name != "legacy" && !Enabled(strategies[i].Name + "_parser") {
+			continue;
+		}
+		restaurants, err := tryStrategy(strategies[i], strData);
+		if err != nil {
+			log.Printf("WARNING: %s parser failed: %s\n", strategies[i].Name, err);
+			continue;
+		}
+		if Plausible(restaurants) {
+			// Some restaurants have several logos or appear in several cells
+			//
+			return MergeDuplicates(restaurants), strategies[i].Name;
+		}
+	}
+	return make([]RestData, 0), "";
+}
+
+// Runs a strategy, turning a panic (usually an index out of range on
+// unexpected markup) into an error
+//
+func tryStrategy(strategy Strategy, in string) (restaurants []RestData, err os.Error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = os.NewError(fmt.Sprint(e));
+		}
+	}();
+	return strategy.Parse(in), nil;
+}
+
+// A result is plausible if there are restaurants and at least half of
+// them have a menu
+//
+func Plausible(restaurants []RestData) bool {
+	var menus = 0;
+	for i := 0; i < len(restaurants); i++ {
+		if restaurants[i].Menu != "" {
+			menus++;
+		}
+	}
+	return len(restaurants) > 0 && menus * 2 >= len(restaurants);
+}
+
+// A tag or a piece of text in the HTML document
+//
+type token struct {
+	tag string;			// Lowercase tag name, "/td" for end tags and empty for text
+	attrs map[string]string;
+	text string;
+	closed bool;			// Written as an empty element, like <br/>
+}
+
+var rx_attr = regexp.MustCompile("([a-zA-Z\\-]+)[ \t\r\n]*=[ \t\r\n]*(\"[^\"]*\"|'[^']*'|[^ \t\r\n]+)");
+
+// Splits the HTML document into tags and text. Comments, declarations
+// and a truncated tag at the end are dropped.
+//
+func tokenize(html string) []token {
+	var tokens = make([]token, 0);
+
+	for len(html) > 0 {
+		var lt = strings.Index(html, "<");
+		if lt < 0 {
+			tokens = append(tokens, token{ text: html });
+			break;
+		}
+		if lt > 0 {
+			tokens = append(tokens, token{ text: html[0:lt] });
+		}
+
+		// A < that doesn't start a tag, like in "< 100 kr", is text
+		//
+		if !tagStart(html[lt + 1:]) {
+			tokens = append(tokens, token{ text: "<" });
+			html = html[lt + 1:];
+			continue;
+		}
+
+		// Comments end at -->, they may hold tags
+		//
+		if strings.HasPrefix(html[lt:], "<!--") {
+			var end = strings.Index(html[lt + 4:], "-->");
+			if end < 0 {
+				break;
+			}
+			html = html[lt + 4 + end + 3:];
+			continue;
+		}
+
+		var gt = strings.Index(html[lt:], ">");
+		if gt < 0 {
+			break;
+		}
+		var inner = strings.TrimSpace(html[lt + 1:lt + gt]);
+		html = html[lt + gt + 1:];
+
+		if strings.HasPrefix(inner, "!") || inner == "" {
+			continue;
+		}
+
+		var t token;
+		t.tag = strings.ToLower(strings.TrimRight(strings.Fields(inner)[0], "/"));
+		t.closed = strings.HasSuffix(inner, "/");
+		t.attrs = make(map[string]string);
+		for _, attr := range rx_attr.FindAllStringSubmatch(inner, -1) {
+			t.attrs[strings.ToLower(attr[1])] = strings.Trim(attr[2], "\"'");
+		}
+		tokens = append(tokens, t);
+	}
+	return tokens;
+}
+
+// Tells if the text after a < makes it a tag: a letter, a /, or a ! or ?
+// for comments and declarations
+//
+func tagStart(rest string) bool {
+	if rest == "" {
+		return true;
+	}
+	var c = rest[0];
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '/' || c == '!' || c == '?';
+}
+
+// Parses the document by walking its tags. A restaurant starts with the
+// cell of its logo, which may have a description within <center>, and
+// the menu is the contents of the following cell.
+//
+func ParseTokens(in string) []RestData {
+	const (
+		outside = iota;
+		logoCell;
+		description;
+		waitMenu;
+		menuCell;
+	)
+
+	var restaurants = make([]RestData, 0);
+	var images = make([]string, 0);
+	var state = outside;
+	var desc, menu bytes.Buffer;
+
+	// Finishes the restaurant that is being parsed, if any
+	//
+	var finish = func() {
+		if len(images) > len(restaurants) {
+			var r RestData;
+			r.Description = strings.TrimSpace(desc.String());
+			r.Menu = strings.TrimSpace(menu.String());
+			restaurants = append(restaurants, r);
+		}
+		desc.Reset();
+		menu.Reset();
+	};
+
+	for _, t := range tokenize(in) {
+		switch {
+		case t.tag == "img" && strings.Index(t.attrs["src"], "lunchlogo/") >= 0:
+			finish();
+			images = append(images, t.attrs["src"]);
+			state = logoCell;
+
+		case state == logoCell && t.tag == "center":
+			state = description;
+		case state == description && t.tag == "/center":
+			state = logoCell;
+		case state == description && t.tag == "":
+			desc.WriteString(t.text);
+		case state == description:
+			desc.WriteString(" ");
+
+		case (state == logoCell || state == description) && t.tag == "/td":
+			state = waitMenu;
+		case state == waitMenu && t.tag == "td":
+			state = menuCell;
+
+		case state == menuCell && t.tag == "/td":
+			state = outside;
+		case state == menuCell && t.tag == "li":
+			menu.WriteString("* ");
+		case state == menuCell && t.tag == "br":
+			menu.WriteString("\n");
+		case state == menuCell && t.tag == "":
+			menu.WriteString(t.text);
+		}
+	}
+	finish();
+
+	for i := 0; i < len(restaurants); i++ {
+		CompleteRestaurant(&restaurants[i], images[i]);
+	}
+	return restaurants;
+}
+
+var rx_logo = regexp.MustCompile("lunchlogo/[^\"' >]+");
+var rx_li   = regexp.MustCompile("<[lL][iI][^>]*>");
+var rx_br   = regexp.MustCompile("<[bB][rR][^>]*>");
+
+// Parses the document by taking all text between two logos as the menu
+// of the first one, with the text within <center> as the description
+//
+func ParseHeuristic(in string) []RestData {
+	var logos = rx_logo.FindAllStringIndex(in, -1);
+	var restaurants = make([]RestData, len(logos));
+
+	for i := 0; i < len(logos); i++ {
+		var end = len(in);
+		if i + 1 < len(logos) {
+			end = logos[i + 1][0];
+		}
+		var block = in[logos[i][1]:end];
+
+		// Skip the rest of the image tag
+		//
+		if gt := strings.Index(block, ">"); gt >= 0 {
+			block = block[gt + 1:];
+		}
+
+		// The description is removed from the block so that what's
+		// left is the menu
+		//
+		if text := rx_text.FindString(block); text != "" {
+			restaurants[i].Description = strings.TrimSpace(rx_html.ReplaceAllString(text, " "));
+			block = strings.Replace(block, text, "", 1);
+		}
+
+		block = rx_li.ReplaceAllString(block, "* ");
+		block = rx_br.ReplaceAllString(block, "\n");
+		restaurants[i].Menu = strings.TrimSpace(rx_html.ReplaceAllString(block, ""));
+
+		CompleteRestaurant(&restaurants[i], in[logos[i][0]:logos[i][1]]);
+	}
+	return restaurants;
+}
@@ -0,0 +1,80 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Mode and owner of the published files, set with -mode and -owner. The
+    web server on some hosts runs as another user in the same group and
+    needs to be able to replace the files.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Mode of the published files, and their owner when running as root
+// (-1 keeps the owner of the process)
+//
+var fileMode uint32 = 0644;
+var fileUid = -1;
+var fileGid = -1;
+
+// Sets the mode of the published files from an octal number like 0664
+//
+func SetupFileMode(mode string) os.Error {
+	value, err := strconv.Btoui64(mode, 8);
+	if err != nil || value > 0777 {
+		return os.NewError("invalid file mode " + mode);
+	}
+	fileMode = uint32(value);
+	return nil;
+}
+
+// Sets the owner of the published files from "uid:gid", where either
+// may be left out
+//
+func SetupFileOwner(owner string) os.Error {
+	if owner == "" {
+		return nil;
+	}
+	var parts = strings.Split(owner, ":", 2);
+	var err os.Error;
+	if parts[0] != "" {
+		if fileUid, err = strconv.Atoi(parts[0]); err != nil {
+			return os.NewError("invalid uid in " + owner);
+		}
+	}
+	if len(parts) == 2 && parts[1] != "" {
+		if fileGid, err = strconv.Atoi(parts[1]); err != nil {
+			return os.NewError("invalid gid in " + owner);
+		}
+	}
+	return nil;
+}
+
+// Writes a published file with the configured mode and owner, creating
+// the directories of its path. The mode is set again after writing since
+// the umask applies to new files.
+//
+func WriteFile(file string, data []byte) os.Error {
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return err;
+	}
+	if err := ioutil.WriteFile(file, data, fileMode); err != nil {
+		return err;
+	}
+	if err := os.Chmod(file, fileMode); err != nil {
+		return err;
+	}
+	if fileUid >= 0 || fileGid >= 0 {
+		return os.Chown(file, fileUid, fileGid);
+	}
+	return nil;
+}
@@ -0,0 +1,74 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Extraction and normalization of the prices written in the menus, like
+    "85:-", "85 kr" or "85/95 kr (pensionär)".
+*/
+
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// One or more prices separated by slashes followed by a currency marker,
+// which must end there so "3 kroppkakor" isn't a price
+//
+var rx_price = regexp.MustCompile("([0-9]+([.,][0-9]+)?([ ]*/[ ]*[0-9]+([.,][0-9]+)?)*)[ ]*(:-|kr|sek)([ \t\r\n.,;:!?()/*]|$)");
+
+// Finds the price of a dish and returns the raw price text, as written,
+// together with all prices found in it as öre. Alternative prices
+// ("85/95 kr") are returned in the order they were written.
+//
+func ExtractPrice(text string) (raw string, ore []int) {
+	text = DecodeEntities(text);
+	var match = rx_price.FindStringSubmatchIndex(asciiLower(text));
+	if len(match) == 0 {
+		return "", nil;
+	}
+
+	var values = strings.Split(text[match[2]:match[3]], "/", -1);
+	ore = make([]int, 0, len(values));
+
+	for i := 0; i < len(values); i++ {
+		if value, ok := ParseOre(strings.TrimSpace(values[i])); ok {
+			ore = append(ore, value);
+		}
+	}
+
+	// The character after the currency marker isn't part of the price
+	//
+	return strings.TrimSpace(text[match[0]:match[12]]), ore;
+}
+
+// Converts a price in kronor, with optional decimals ("85", "85,50" or
+// "85.5"), into öre
+//
+func ParseOre(value string) (int, bool) {
+	var parts = strings.Split(strings.Replace(value, ",", ".", -1), ".", 2);
+
+	kronor, err := strconv.Atoi(parts[0]);
+	if err != nil {
+		return 0, false;
+	}
+	if len(parts) == 1 {
+		return kronor * 100, true;
+	}
+
+	// Only the first two decimals are significant
+	//
+	var decimals = parts[1];
+	if len(decimals) == 1 {
+		decimals += "0";
+	}
+	ore, err := strconv.Atoi(decimals[0:2]);
+	if err != nil {
+		return 0, false;
+	}
+	return kronor * 100 + ore, true;
+}
@@ -0,0 +1,47 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Tests of the extraction of prices.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+var priceTests = []struct {
+	text, raw string;
+	ore []int;
+} {
+	{ "Pannbiff med lök 85:-", "85:-", []int{ 8500 } },
+	{ "Dagens fisk 85 kr", "85 kr", []int{ 8500 } },
+	{ "Dagens fisk 85kr.", "85kr", []int{ 8500 } },
+	{ "Buffé 85/95 kr (pensionär)", "85/95 kr", []int{ 8500, 9500 } },
+	{ "Sallad 79,50 SEK", "79,50 SEK", []int{ 7950 } },
+	{ "Kaffe (12 kr)", "12 kr", []int{ 1200 } },
+	{ "Räksmörgås ÅÄÖ 95 KR", "95 KR", []int{ 9500 } },
+	{ "3 kroppkakor med lingon 95 kr", "95 kr", []int{ 9500 } },
+	{ "3 kroppkakor med lingon", "", nil },
+	{ "2 sektioner av pizza", "", nil },
+	{ "Pytt i panna", "", nil },
+};
+
+func TestExtractPrice(t *testing.T) {
+	for _, test := range priceTests {
+		raw, ore := ExtractPrice(test.text);
+		if raw != test.raw || len(ore) != len(test.ore) {
+			t.Errorf("ExtractPrice(%q) = %q, %v, want %q, %v", test.text, raw, ore, test.raw, test.ore);
+			continue;
+		}
+		for i := 0; i < len(ore); i++ {
+			if ore[i] != test.ore[i] {
+				t.Errorf("ExtractPrice(%q) = %q, %v, want %q, %v", test.text, raw, ore, test.raw, test.ore);
+				break;
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Pre-flight check of the upstream site ("probe"): every provider in the
+    configuration requests the first weekday, and the reachability,
+    latency, certificate and parsed restaurants are reported in a table.
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"http"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Time budget of every probe
+//
+const probeTimeout = 30e9;
+
+// Probes all providers and prints the table, returns false if any of
+// them failed
+//
+func Probe(rawurl string, weekdays []string, week int, year int) bool {
+	var names = make([]string, 0, len(config.Providers));
+	for name, _ := range config.Providers {
+		names = append(names, name);
+	}
+	sort.SortStrings(names);
+
+	var ok = true;
+	fmt.Printf("%-16s %-12s %8s %8s %-20s %s\n", "PROVIDER", "STATUS", "MS", "BYTES", "RESTAURANTS", "CERTIFICATE");
+
+	for i := 0; i < len(names); i++ {
+		var provider = config.Providers[names[i]];
+		var request = provider.Request(map[string]string {
+			"url":	rawurl,
+			"day":	provider.Day(0, weekdays),
+			"week":	fmt.Sprint(week),
+			"year":	fmt.Sprint(year),
+		});
+
+		var start = time.Nanoseconds();
+		inData, err := FetchWithin(request, probeTimeout);
+		var ms = (time.Nanoseconds() - start) / 1e6;
+
+		var status, restaurants = "ok", "-";
+		if err != nil {
+			status = ClassifyError(err);
+			ok = false;
+		} else {
+			list, parser := Parse(inData);
+			restaurants = fmt.Sprintf("%d (%s)", len(list), parser);
+			if len(list) == 0 {
+				status = "no-markers";
+				ok = false;
+			}
+		}
+
+		var certificate = "-";
+		if strings.HasPrefix(request.Url, "https:") {
+			days, err := CertificateDays(request.Url);
+			switch {
+			case err != nil:
+				certificate = err.String();
+				ok = false;
+			case days < 14:
+				certificate = fmt.Sprintf("expires in %d days!", days);
+				ok = false;
+			default:
+				certificate = fmt.Sprintf("expires in %d days", days);
+			}
+		}
+
+		fmt.Printf("%-16s %-12s %8d %8d %-20s %s\n", names[i], status, ms, len(inData), restaurants, certificate);
+	}
+	return ok;
+}
+
+// Returns the number of days until the certificate of the server
+// expires, verified with the TLS settings of the run
+//
+func CertificateDays(rawurl string) (int, os.Error) {
+	u, err := http.ParseURL(rawurl);
+	if err != nil {
+		return 0, err;
+	}
+	var host = strings.Split(u.Host, ":", 2)[0];
+	var addr = u.Host;
+	if strings.Index(addr, ":") < 0 {
+		addr += ":443";
+	}
+
+	conn, err := net.Dial(transport.Network, transport.LocalAddr, addr);
+	if err != nil {
+		return 0, err;
+	}
+	defer conn.Close();
+
+	var config = *tlsConfig;
+	config.ServerName = host;
+	var tlsConn = tls.Client(conn, &config);
+	if err = tlsConn.Handshake(); err != nil {
+		return 0, err;
+	}
+
+	var certs = tlsConn.ConnectionState().PeerCertificates;
+	if len(certs) == 0 {
+		return 0, os.NewError("no certificate");
+	}
+	return int((certs[0].NotAfter.Seconds() - time.Seconds()) / daySeconds), nil;
+}
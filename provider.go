@@ -0,0 +1,92 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Providers describe how the menu of a day is requested from a site
+    running Lunchguiden. Some mirrors want the day as a GET parameter and
+    some want a form POST with hidden fields, so the URL and the body are
+    templates where {url}, {day}, {week} and {year} are replaced. The
+    values of the weekdays differ between sites too (Måndag, Mandag or 1),
+    so a provider may have its own.
+*/
+
+package main
+
+import (
+	"http"
+	"strings"
+)
+
+type Provider struct {
+	Method string;			// GET or POST, GET if empty
+	Url string;			// Template of the URL of a day
+	Body string;			// Template of the request body, for POST
+	ContentType string;		// Content type of the body, a form if empty
+	Charset string;			// Character set of the site, detected if empty
+	Days []string;			// Values of monday to friday, unescaped, -days is used if empty
+	ImageBase string;		// URL the logo links are relative to, the one of Lunchguiden if empty
+}
+
+// Content types accepted for the documents of the days, text/plain for
+// servers that don't know what they're serving
+//
+var htmlTypes = []string{ "text/html", "application/xhtml+xml", "text/plain" };
+
+// The provider used when none is configured, the original Lunchguiden
+//
+var defaultProvider = Provider{ Method: "GET", Url: "{url}&veckodag={day}" };
+
+// Returns the value of a weekday (0 = monday) for the provider, unescaped
+//
+func (p *Provider) Day(day int, weekdays []string) string {
+	if len(p.Days) == 5 {
+		return p.Days[day];
+	}
+	if value, err := http.URLUnescape(weekdays[day]); err == nil {
+		return value;
+	}
+	return weekdays[day];
+}
+
+// Returns the request for downloading a day from the provider. Values
+// in the body are escaped since it's a form. In the URL only the day is
+// escaped, the other values are numbers or the URL of the command line
+// which is used as it is. Escaping is done in the character set of the
+// site.
+//
+func (p *Provider) Request(values map[string]string) *Request {
+	var escaped = make(map[string]string);
+	for name, value := range values {
+		escaped[name] = EscapeValue(value, p.Charset);
+	}
+	var urlValues = make(map[string]string);
+	for name, value := range values {
+		urlValues[name] = value;
+	}
+	urlValues["day"] = escaped["day"];
+
+	var r = &Request{ Method: p.Method, Url: ExpandTemplate(p.Url, urlValues), Charset: p.Charset, Types: htmlTypes };
+
+	if r.Method == "" {
+		r.Method = "GET";
+	}
+	if r.Method == "POST" {
+		r.Body = ExpandTemplate(p.Body, escaped);
+		r.ContentType = p.ContentType;
+		if r.ContentType == "" {
+			r.ContentType = "application/x-www-form-urlencoded";
+		}
+	}
+	return r;
+}
+
+// Replaces the {name} placeholders in the template with their values
+//
+func ExpandTemplate(template string, values map[string]string) string {
+	for name, value := range values {
+		template = strings.Replace(template, "{" + name + "}", value, -1);
+	}
+	return template;
+}
@@ -0,0 +1,135 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Canonical restaurant identities, used to merge restaurants that show
+    up more than once (several logo files for the same restaurant, or the
+    same restaurant in two cells of the table), and the overrides of the
+    configuration keyed by them.
+*/
+
+package main
+
+import (
+	"bytes"
+	"path"
+	"strings"
+)
+
+// Returns a stable identifier for a restaurant derived from its name in
+// the image table, or from the image file name for unknown restaurants
+//
+func RestaurantId(name string, image string) string {
+	if name == "" {
+		name = strings.Split(path.Base(image), ".", 2)[0];
+	}
+	return Slug(name);
+}
+
+// Turns a name into a lowercase identifier with dashes between the words.
+// Swedish characters are folded to their plain ASCII letters.
+//
+func Slug(name string) string {
+	var folded = strings.ToLower(DecodeEntities(name));
+	var id = bytes.NewBuffer(make([]byte, 0, len(folded)));
+	var dash = false;
+
+	for _, c := range folded {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+			id.WriteRune(c);
+			dash = false;
+		case c == 'å' || c == 'ä':
+			id.WriteRune('a');
+			dash = false;
+		case c == 'ö':
+			id.WriteRune('o');
+			dash = false;
+		case c == 'é' || c == 'è':
+			id.WriteRune('e');
+			dash = false;
+		case c == 'ü':
+			id.WriteRune('u');
+			dash = false;
+		default:
+			if !dash && id.Len() > 0 {
+				id.WriteRune('-');
+				dash = true;
+			}
+		}
+	}
+	return strings.TrimRight(id.String(), "-");
+}
+
+// Merges restaurants with the same identifier into the first occurrence,
+// keeping the order of the table. Menus that differ are joined, identical
+// menus (the same cell twice) are only kept once.
+//
+func MergeDuplicates(restaurants []RestData) []RestData {
+	var merged = make([]RestData, 0, len(restaurants));
+	var seen = make(map[string]int);
+
+	for i := 0; i < len(restaurants); i++ {
+		var r = restaurants[i];
+
+		index, found := seen[r.Id];
+		if !found {
+			seen[r.Id] = len(merged);
+			merged = append(merged, r);
+			continue;
+		}
+
+		var first = &merged[index];
+		if first.Menu != r.Menu && r.Menu != "" {
+			first.Menu = strings.TrimSpace(first.Menu + "\n" + r.Menu);
+			first.Dishes = append(first.Dishes, r.Dishes...);
+		}
+		if first.Description == "" {
+			first.Description = r.Description;
+		}
+		if first.Special == "" {
+			first.Special = r.Special;
+		}
+	}
+	return merged;
+}
+
+// Applies the overrides of the configuration to the restaurants and
+// returns the list without the hidden ones
+//
+func ApplyOverrides(list []RestData, overrides map[string]Override) []RestData {
+	if len(overrides) == 0 {
+		return list;
+	}
+
+	var result = make([]RestData, 0, len(list));
+	for i := 0; i < len(list); i++ {
+		var r = list[i];
+		override, found := overrides[r.Id];
+		if !found {
+			result = append(result, r);
+			continue;
+		}
+		if override.Hidden {
+			continue;
+		}
+
+		if override.Name != "" {
+			r.Name = override.Name;
+		}
+		if override.ImageUrl != "" {
+			r.ImageUrl = override.ImageUrl;
+		}
+		for j := 0; j < len(r.Dishes); j++ {
+			for k := 0; k < len(override.Tags); k++ {
+				if !contains(r.Dishes[j].Categories, override.Tags[k]) {
+					r.Dishes[j].Categories = append(r.Dishes[j].Categories, override.Tags[k]);
+				}
+			}
+		}
+		result = append(result, r);
+	}
+	return result;
+}
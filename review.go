@@ -0,0 +1,134 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Holding of suspicious results for manual review. Instead of being
+    published they are written to a staging directory, from where they
+    are published with "lunchguiden approve <file>".
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// Decides if the data should be held for review, either because a day
+// has a low confidence score or because it differs too much from the
+// previous version of the week in the archive, nil if there is none.
+// The archive has the whole week, unlike the published file which may
+// be trimmed to the size budget. Returns the reason for holding.
+//
+func NeedsReview(data *DataStruct, previous *DataStruct, minConfidence float64, maxDiff float64) (bool, string) {
+	for day := 0; day < 5; day++ {
+		if data.Days[day].Holiday != "" {
+			continue;
+		}
+		if data.Days[day].Confidence < minConfidence {
+			return true, fmt.Sprintf("confidence of %s is %.2f", data.Days[day].Name, data.Days[day].Confidence);
+		}
+	}
+
+	if previous == nil {
+		return false, "";
+	}
+	if diff := MenuDiff(previous, data); diff > maxDiff {
+		return true, fmt.Sprintf("%.0f%% of the menus changed", diff * 100);
+	}
+	return false, "";
+}
+
+// Returns the fraction of restaurant menus, over all days, that were
+// added, removed or changed between two versions of a week
+//
+func MenuDiff(previous *DataStruct, current *DataStruct) float64 {
+	var total, changed = 0, 0;
+
+	for day := 0; day < 5; day++ {
+		var menus = make(map[string]string);
+		for _, r := range previous.Days[day].Restaurants {
+			menus[r.Id] = r.Menu;
+		}
+
+		for _, r := range current.Days[day].Restaurants {
+			menu, found := menus[r.Id];
+			if !found || menu != r.Menu {
+				changed++;
+			}
+			menus[r.Id] = "", false;
+			total++;
+		}
+
+		// Restaurants only in the previous version were removed
+		//
+		total += len(menus);
+		changed += len(menus);
+	}
+
+	if total == 0 {
+		return 0;
+	}
+	return float64(changed) / float64(total);
+}
+
+// Writes the data and its md5 sum to the staging directory, together with
+// a file telling where it should be published when approved. The whole
+// week for the archive is kept next to it if the data was trimmed to the
+// size budget.
+//
+func Stage(dir string, out string, outData []byte, fullData []byte, hash []byte) os.Error {
+	var staged = fmt.Sprintf("%s/%s", dir, path.Base(out));
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err;
+	}
+	if err := ioutil.WriteFile(staged, outData, 0644); err != nil {
+		return err;
+	}
+	if err := ioutil.WriteFile(staged + ".md5", hash, 0644); err != nil {
+		return err;
+	}
+	if !bytes.Equal(outData, fullData) {
+		if err := ioutil.WriteFile(staged + ".full", fullData, 0644); err != nil {
+			return err;
+		}
+	}
+	fmt.Printf("Staged %s, publish it with \"lunchguiden approve %s\"\n", staged, staged);
+	return ioutil.WriteFile(staged + ".target", []byte(out), 0644);
+}
+
+// Publishes a staged file to the path it was meant for and removes it
+// from the staging directory. The whole week for the archive is
+// returned, which is the published data unless it was trimmed.
+//
+func Approve(staged string) ([]byte, os.Error) {
+	target, err := ioutil.ReadFile(staged + ".target");
+	if err != nil {
+		return nil, err;
+	}
+	outData, err := ioutil.ReadFile(staged);
+	if err != nil {
+		return nil, err;
+	}
+
+	var _, hash = GenerateHash(outData);
+	if err = Publish(strings.TrimSpace(string(target)), outData, hash); err != nil {
+		return nil, err;
+	}
+	if fullData, err := ioutil.ReadFile(staged + ".full"); err == nil {
+		outData = fullData;
+	}
+
+	os.Remove(staged);
+	os.Remove(staged + ".md5");
+	os.Remove(staged + ".target");
+	os.Remove(staged + ".full");
+	return outData, nil;
+}
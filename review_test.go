@@ -0,0 +1,76 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Tests of the review of suspicious results.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+// Returns a week with the same restaurants and menus every day
+//
+func reviewWeek(menus []string) *DataStruct {
+	var data = new(DataStruct);
+	for day := 0; day < 5; day++ {
+		data.Days[day].Name = weekdayNames["sv"][day];
+		data.Days[day].Confidence = 1;
+		for i := 0; i < len(menus); i++ {
+			var r = RestData{ Id: string('a' + i), Menu: menus[i] };
+			data.Days[day].Restaurants = append(data.Days[day].Restaurants, r);
+		}
+	}
+	return data;
+}
+
+var menuDiffTests = []struct {
+	previous, current []string;
+	diff float64;
+}{
+	{ []string { "Fisk", "Soppa" }, []string { "Fisk", "Soppa" }, 0 },
+	{ []string { "Fisk", "Soppa" }, []string { "Fisk", "Pytt" }, 0.5 },
+	{ []string { "Fisk", "Soppa" }, []string { "Fisk" }, 0.5 },
+	{ []string { "Fisk" }, []string { "Fisk", "Soppa" }, 0.5 },
+	{ []string { "Fisk", "Soppa" }, []string { "Lax", "Pytt" }, 1 },
+	{ []string {}, []string {}, 0 },
+}
+
+func TestMenuDiff(t *testing.T) {
+	for _, test := range menuDiffTests {
+		if diff := MenuDiff(reviewWeek(test.previous), reviewWeek(test.current)); diff != test.diff {
+			t.Errorf("MenuDiff(%v, %v) = %v, want %v", test.previous, test.current, diff, test.diff);
+		}
+	}
+}
+
+func TestNeedsReview(t *testing.T) {
+	var previous = reviewWeek([]string { "Fisk", "Soppa", "Pytt", "Lax" });
+
+	if hold, reason := NeedsReview(reviewWeek([]string { "Fisk", "Soppa", "Pytt", "Lax" }), previous, 0.5, 0.5); hold {
+		t.Errorf("same week held, %s", reason);
+	}
+	if hold, _ := NeedsReview(reviewWeek([]string { "Köttbullar", "Soppa", "Pytt", "Lax" }), previous, 0.5, 0.5); hold {
+		t.Errorf("week with a fourth of the menus changed held");
+	}
+	if hold, _ := NeedsReview(reviewWeek([]string { "Köttbullar", "Kyckling", "Pasta", "Lax" }), previous, 0.5, 0.5); !hold {
+		t.Errorf("week with most menus changed not held");
+	}
+	if hold, _ := NeedsReview(reviewWeek([]string { "Köttbullar", "Kyckling", "Pasta" }), nil, 0.5, 0.5); hold {
+		t.Errorf("week held without a previous week");
+	}
+
+	var doubtful = reviewWeek([]string { "Fisk" });
+	doubtful.Days[2].Confidence = 0.2;
+	if hold, _ := NeedsReview(doubtful, previous, 0.5, 1); !hold {
+		t.Errorf("week with a day of low confidence not held");
+	}
+	doubtful.Days[2].Holiday = "Kristi himmelsfärd";
+	if hold, reason := NeedsReview(doubtful, nil, 0.5, 1); hold {
+		t.Errorf("week held for a holiday, %s", reason);
+	}
+}
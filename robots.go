@@ -0,0 +1,163 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Support for robots.txt, so that the paths disallowed by the site are
+    never downloaded and the Crawl-delay is kept between requests.
+*/
+
+package main
+
+import (
+	"http"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The name used for finding the rules of lunchguiden in robots.txt
+//
+const robotName = "lunchguiden";
+
+// The rules of robots.txt that apply to lunchguiden
+//
+type Robots struct {
+	Allow []string;
+	Disallow []string;
+	CrawlDelay float64;		// Seconds
+}
+
+// Time budget of downloading robots.txt
+//
+const robotsTimeout = 30e9;
+
+// Downloads and parses the robots.txt of the host of the URL. A missing
+// or forbidden robots.txt (4xx) allows everything. A robots.txt that
+// can't be downloaded, because of a server error (5xx), a timeout or the
+// network, allows everything as well, with a warning.
+//
+func FetchRobots(pageUrl string) (*Robots, os.Error) {
+	u, err := http.ParseURL(pageUrl);
+	if err != nil {
+		return nil, err;
+	}
+
+	data, err := FetchWithin(&Request{ Method: "GET", Url: u.Scheme + "://" + u.Host + "/robots.txt" }, robotsTimeout);
+	if e, ok := err.(*StatusError); ok && strings.HasPrefix(e.Status, "4") {
+		return new(Robots), nil;
+	}
+	if err != nil {
+		log.Printf("WARNING: Unable to download robots.txt, allowing everything: %s\n", err);
+		return new(Robots), nil;
+	}
+	return ParseRobots(string(data)), nil;
+}
+
+// Reads robots.txt from a file, used for overriding the one of the site
+//
+func ReadRobots(path string) (*Robots, os.Error) {
+	data, err := ioutil.ReadFile(path);
+	if err != nil {
+		return nil, err;
+	}
+	return ParseRobots(string(data)), nil;
+}
+
+// Parses robots.txt and returns the rules for lunchguiden, or the rules
+// for all robots (*) if there are none specifically for lunchguiden
+//
+func ParseRobots(text string) *Robots {
+	var named, any = new(Robots), new(Robots);
+	var foundNamed = false;
+	var current []*Robots;
+	var inRules = false;
+
+	for _, line := range strings.Split(text, "\n", -1) {
+		if hash := strings.Index(line, "#"); hash >= 0 {
+			line = line[0:hash];
+		}
+		var parts = strings.Split(line, ":", 2);
+		if len(parts) != 2 {
+			continue;
+		}
+		var field = strings.ToLower(strings.TrimSpace(parts[0]));
+		var value = strings.TrimSpace(parts[1]);
+
+		// A group starts with one or more User-agent lines
+		//
+		if field == "user-agent" {
+			if inRules {
+				current = nil;
+				inRules = false;
+			}
+			// Only the product token is compared, "lunchguiden/1.0"
+			// is lunchguiden but "lunch" or "lunchguidenbot" isn't
+			//
+			var agent = strings.TrimSpace(strings.Split(strings.ToLower(value), "/", 2)[0]);
+			if agent == "*" {
+				current = append(current, any);
+			} else if agent == robotName {
+				current = append(current, named);
+				foundNamed = true;
+			}
+			continue;
+		}
+
+		inRules = true;
+		for _, r := range current {
+			switch field {
+			case "allow":
+				r.Allow = append(r.Allow, value);
+			case "disallow":
+				if value != "" {
+					r.Disallow = append(r.Disallow, value);
+				}
+			case "crawl-delay":
+				if delay, err := strconv.Atof64(value); err == nil {
+					r.CrawlDelay = delay;
+				}
+			}
+		}
+	}
+
+	if foundNamed {
+		return named;
+	}
+	return any;
+}
+
+// Checks if the path, with the query string, may be downloaded. The
+// longest matching rule wins and allow wins over disallow on ties.
+//
+func (r *Robots) Allowed(path string) bool {
+	var allowed, longest = true, -1;
+
+	for _, rule := range r.Disallow {
+		if strings.HasPrefix(path, rule) && len(rule) > longest {
+			allowed, longest = false, len(rule);
+		}
+	}
+	for _, rule := range r.Allow {
+		if strings.HasPrefix(path, rule) && len(rule) >= longest {
+			allowed, longest = true, len(rule);
+		}
+	}
+	return allowed;
+}
+
+// Returns the path and query string of a URL
+//
+func RequestPath(rawurl string) string {
+	u, err := http.ParseURL(rawurl);
+	if err != nil {
+		return rawurl;
+	}
+	if u.RawQuery != "" {
+		return u.Path + "?" + u.RawQuery;
+	}
+	return u.Path;
+}
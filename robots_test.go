@@ -0,0 +1,65 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Tests of the rules of robots.txt.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+const robotsAll = `
+User-agent: *
+Disallow: /admin
+Disallow: /lunch.asp?print
+Crawl-delay: 2
+`;
+
+var robotsTests = []struct {
+	text string;
+	path string;
+	allowed bool;
+	delay float64;
+}{
+	{ robotsAll, "/lunch.asp?stad=falun", true, 2 },
+	{ robotsAll, "/admin/login", false, 2 },
+	{ robotsAll, "/lunch.asp?print=1", false, 2 },
+	{ "", "/admin", true, 0 },
+
+	// The group of lunchguiden wins over the one of all robots
+	//
+	{ robotsAll + "\nUser-agent: LunchGuiden/1.0\nDisallow: /lunch\n", "/admin", true, 0 },
+	{ robotsAll + "\nUser-agent: LunchGuiden/1.0\nDisallow: /lunch\n", "/lunch.asp", false, 0 },
+	{ "User-agent: googlebot\nUser-agent: lunchguiden\nDisallow: /\n", "/lunch.asp", false, 0 },
+
+	// Only the whole product token is lunchguiden
+	//
+	{ robotsAll + "\nUser-agent: lunch\nDisallow: /\n", "/lunch.asp", true, 2 },
+	{ robotsAll + "\nUser-agent: lunchguidenbot\nDisallow: /\n", "/lunch.asp", true, 2 },
+	{ robotsAll + "\nUser-agent:\nDisallow: /\n", "/lunch.asp", true, 2 },
+
+	// The longest rule wins, allow wins ties
+	//
+	{ "User-agent: *\nDisallow: /lunch\nAllow: /lunch.asp\n", "/lunch.asp?stad=falun", true, 0 },
+	{ "User-agent: *\nAllow: /lunch\nDisallow: /lunch.asp\n", "/lunch.asp?stad=falun", false, 0 },
+	{ "User-agent: *\nAllow: /lunch\nDisallow: /lunch\n", "/lunch.asp", true, 0 },
+	{ "User-agent: *\nDisallow:\n", "/lunch.asp", true, 0 },
+	{ "User-agent: * # all\nDisallow: /lunch # not today\n", "/lunch.asp", false, 0 },
+}
+
+func TestRobots(t *testing.T) {
+	for i, test := range robotsTests {
+		var rules = ParseRobots(test.text);
+		if allowed := rules.Allowed(test.path); allowed != test.allowed {
+			t.Errorf("test %d: Allowed(%q) = %v, want %v", i, test.path, allowed, test.allowed);
+		}
+		if rules.CrawlDelay != test.delay {
+			t.Errorf("test %d: crawl delay %v, want %v", i, rules.CrawlDelay, test.delay);
+		}
+	}
+}
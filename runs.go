@@ -0,0 +1,111 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    History of the runs, one JSON object per line in the runs file of the
+    archive, listed with "lunchguiden -archive <dir> runs".
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"json"
+	"os"
+	"strings"
+	"time"
+)
+
+type Run struct {
+	City string;
+	Week int;
+	Start int64;			// Seconds since the epoch
+	End int64;
+	Days [5]string;			// Restaurants found, "holiday" or the error class of the download
+	BytesFetched int64;
+	Warnings int;
+	Hash string;			// md5 of the published data
+	Result string;			// "published", "held", "failed" or "invalid"
+}
+
+// Returns a new run of a city and week, started now
+//
+func StartRun(city string, week int) *Run {
+	return &Run{ City: city, Week: week, Start: time.Seconds(), Result: "failed" };
+}
+
+// Records the results of the days of the parsed week
+//
+func (run *Run) SetDays(data *DataStruct) {
+	for day := 0; day < 5; day++ {
+		var d = &data.Days[day];
+		switch {
+		case d.Error != "":
+			run.Days[day] = d.Error;
+		case d.Holiday != "" && len(d.Restaurants) == 0:
+			run.Days[day] = "holiday";
+		default:
+			run.Days[day] = fmt.Sprint(len(d.Restaurants) + len(d.Specials));
+		}
+	}
+}
+
+// Finishes the run and appends it to the runs file of the archive
+//
+func (run *Run) Record(dir string) os.Error {
+	run.End = time.Seconds();
+	run.BytesFetched = bytesFetched;
+	run.Warnings = len(warnings);
+
+	line, err := json.Marshal(run);
+	if err != nil {
+		return err;
+	}
+
+	f, err := os.Open(fmt.Sprintf("%s/runs", dir), os.O_WRONLY | os.O_CREAT | os.O_APPEND, 0644);
+	if err != nil {
+		return err;
+	}
+	defer f.Close();
+	_, err = fmt.Fprintf(f, "%s\n", line);
+	return err;
+}
+
+// Prints the recorded runs, of one city or all cities if city is empty,
+// the latest last
+//
+func ListRuns(dir string, city string) os.Error {
+	f, err := os.Open(fmt.Sprintf("%s/runs", dir), os.O_RDONLY, 0);
+	if err != nil {
+		return err;
+	}
+	defer f.Close();
+
+	fmt.Printf("%-16s  %-12s  %4s  %5s  %-24s  %8s  %4s  %-9s  %s\n", "Started", "City", "Week", "Secs", "Days", "Bytes", "Warn", "Result", "MD5");
+	var r = bufio.NewReader(f);
+	for {
+		line, err := r.ReadString('\n');
+		if err == os.EOF {
+			break;
+		}
+		if err != nil {
+			return err;
+		}
+
+		var run Run;
+		if err = json.Unmarshal([]byte(line), &run); err != nil {
+			return os.NewError("invalid run: " + err.String());
+		}
+		if city != "" && run.City != city {
+			continue;
+		}
+		fmt.Printf("%-16s  %-12s  %4d  %5d  %-24s  %8d  %4d  %-9s  %s\n",
+			time.SecondsToLocalTime(run.Start).Format("2006-01-02 15:04"), run.City, run.Week,
+			run.End - run.Start, strings.Join(run.Days[0:], ","), run.BytesFetched,
+			run.Warnings, run.Result, run.Hash);
+	}
+	return nil;
+}
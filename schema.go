@@ -0,0 +1,183 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    JSON Schema of the output and a small validator for the parts of JSON
+    Schema it uses (type, required, properties, items, minItems, maxItems,
+    minLength, minimum and maximum). Generated documents are validated
+    before they are written so that malformed data is never published.
+*/
+
+package main
+
+import (
+	"fmt"
+	"json"
+	"os"
+)
+
+// Version of the output format, bumped on incompatible changes
+//
+const SchemaVersion = 1;
+
+// The schema of the output for the current version
+//
+const schemaV1 = `{
+	"type": "object",
+	"required": [ "City", "Week", "Days" ],
+	"properties": {
+		"SchemaVersion": { "type": "integer", "minimum": 1 },
+		"City": { "type": "string", "minLength": 1 },
+		"Year": { "type": "integer", "minimum": 2000 },
+		"Week": { "type": "integer", "minimum": 1, "maximum": 53 },
+		"Days": {
+			"type": "array", "minItems": 5, "maxItems": 5,
+			"items": {
+				"type": "object",
+				"required": [ "Day", "Name", "Restaurants" ],
+				"properties": {
+					"Day":  { "type": "integer", "minimum": 0, "maximum": 4 },
+					"Name": { "type": "string", "minLength": 1 },
+					"Date": { "type": "string" },
+					"Restaurants": {
+						"type": [ "array", "null" ],
+						"items": {
+							"type": "object",
+							"required": [ "Id", "Name", "ImageUrl", "Description", "Menu" ],
+							"properties": {
+								"Id":          { "type": "string", "minLength": 1 },
+								"Name":        { "type": "string" },
+								"ImageUrl":    { "type": "string" },
+								"Description": { "type": "string" },
+								"Menu":        { "type": "string" },
+								"Dishes": {
+									"type": [ "array", "null" ],
+									"items": {
+										"type": "object",
+										"required": [ "Text" ],
+										"properties": {
+											"Text": { "type": "string", "minLength": 1 },
+											"PriceOre": {
+												"type": [ "array", "null" ],
+												"items": { "type": "integer", "minimum": 0 }
+											}
+										}
+									}
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}`;
+
+// Validates a generated JSON document against the schema, the returned
+// error tells which constraint failed and where
+//
+func ValidateOutput(data []byte) os.Error {
+	var schema, doc interface{};
+
+	if err := json.Unmarshal([]byte(schemaV1), &schema); err != nil {
+		return err;
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err;
+	}
+	return validate(schema.(map[string]interface{}), doc, "$");
+}
+
+// Recursively validates a value against a schema node
+//
+func validate(schema map[string]interface{}, value interface{}, path string) os.Error {
+	if types, ok := schema["type"]; ok && !matchesType(types, value) {
+		return schemaError(path, "type", fmt.Sprintf("expected %v", types));
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, ok := v[name.(string)]; !ok {
+					return schemaError(path, "required", fmt.Sprintf("missing %s", name));
+				}
+			}
+		}
+		if properties, ok := schema["properties"].(map[string]interface{}); ok {
+			for name, property := range properties {
+				if field, ok := v[name]; ok {
+					var err = validate(property.(map[string]interface{}), field, path + "." + name);
+					if err != nil {
+						return err;
+					}
+				}
+			}
+		}
+
+	case []interface{}:
+		if min, ok := schema["minItems"].(float64); ok && float64(len(v)) < min {
+			return schemaError(path, "minItems", fmt.Sprintf("%d items, expected at least %v", len(v), min));
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(v)) > max {
+			return schemaError(path, "maxItems", fmt.Sprintf("%d items, expected at most %v", len(v), max));
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i := 0; i < len(v); i++ {
+				var err = validate(items, v[i], fmt.Sprintf("%s[%d]", path, i));
+				if err != nil {
+					return err;
+				}
+			}
+		}
+
+	case string:
+		if min, ok := schema["minLength"].(float64); ok && float64(len(v)) < min {
+			return schemaError(path, "minLength", fmt.Sprintf("length %d, expected at least %v", len(v), min));
+		}
+
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && v < min {
+			return schemaError(path, "minimum", fmt.Sprintf("%v is less than %v", v, min));
+		}
+		if max, ok := schema["maximum"].(float64); ok && v > max {
+			return schemaError(path, "maximum", fmt.Sprintf("%v is greater than %v", v, max));
+		}
+	}
+	return nil;
+}
+
+// Checks the value against a type name or a list of type names
+//
+func matchesType(types interface{}, value interface{}) bool {
+	if list, ok := types.([]interface{}); ok {
+		for _, t := range list {
+			if matchesType(t, value) {
+				return true;
+			}
+		}
+		return false;
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return types == "object";
+	case []interface{}:
+		return types == "array";
+	case string:
+		return types == "string";
+	case bool:
+		return types == "boolean";
+	case float64:
+		return types == "number" || (types == "integer" && v == float64(int64(v)));
+	case nil:
+		return types == "null";
+	}
+	return false;
+}
+
+func schemaError(path string, constraint string, message string) os.Error {
+	return os.NewError(fmt.Sprintf("%s: %s constraint failed, %s", path, constraint, message));
+}
@@ -0,0 +1,63 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Tests of the validation of the output against the schema.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Returns a week with the given week number, Monday and restaurant on
+// Tuesday, valid with the values below
+//
+func schemaDoc(week string, monday string, restaurant string) string {
+	return `{ "City": "Falun", "Year": 2011, "Week": ` + week + `, "Days": [ ` + monday + `,
+		{ "Day": 1, "Name": "Tisdag", "Restaurants": [ ` + restaurant + ` ] },
+		{ "Day": 2, "Name": "Onsdag", "Restaurants": null },
+		{ "Day": 3, "Name": "Torsdag", "Restaurants": [] },
+		{ "Day": 4, "Name": "Fredag", "Restaurants": [] } ] }`;
+}
+
+const schemaRestaurant = `{ "Id": "ester", "Name": "Café Ester", "ImageUrl": "", "Description": "", "Menu": "Fisk", "Dishes": [ { "Text": "Fisk", "PriceOre": [ 8500 ] } ] }`;
+const schemaDay = `{ "Day": 0, "Name": "Måndag", "Restaurants": [] }`;
+
+var schemaTests = []struct {
+	doc string;
+	err string;			// Part of the error, empty if valid
+}{
+	{ schemaDoc("7", schemaDay, schemaRestaurant), "" },
+	{ schemaDoc("54", schemaDay, schemaRestaurant), "$.Week: maximum constraint failed" },
+	{ schemaDoc("\"7\"", schemaDay, schemaRestaurant), "$.Week: type constraint failed" },
+	{ schemaDoc("7", `{ "Day": 0, "Restaurants": [] }`, schemaRestaurant), "$.Days[0]: required constraint failed, missing Name" },
+	{ schemaDoc("7", `{ "Day": 0, "Name": "", "Restaurants": [] }`, schemaRestaurant), "$.Days[0].Name: minLength constraint failed" },
+	{ schemaDoc("7", schemaDay, `{ "Id": "", "Name": "", "ImageUrl": "", "Description": "", "Menu": "" }`), "$.Days[1].Restaurants[0].Id: minLength" },
+	{ schemaDoc("7", schemaDay, `{ "Id": "ester", "Name": "", "ImageUrl": "", "Description": "" }`), "$.Days[1].Restaurants[0]: required constraint failed, missing Menu" },
+	{ schemaDoc("7", schemaDay, `{ "Id": "ester", "Name": "", "ImageUrl": "", "Description": "", "Menu": "", "Dishes": [ { "Text": "Fisk", "PriceOre": [ -5 ] } ] }`), "PriceOre[0]: minimum constraint failed" },
+	{ `{ "City": "Falun", "Week": 7, "Days": [] }`, "$.Days: minItems constraint failed" },
+	{ `{ "City": "Falun", "Week": 7 }`, "$: required constraint failed, missing Days" },
+}
+
+func TestValidateOutput(t *testing.T) {
+	for i, test := range schemaTests {
+		var err = ValidateOutput([]byte(test.doc));
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("test %d: valid document rejected: %s", i, err);
+		case test.err != "" && err == nil:
+			t.Errorf("test %d: accepted, want %q", i, test.err);
+		case test.err != "" && strings.Index(err.String(), test.err) < 0:
+			t.Errorf("test %d: error is %q, want %q", i, err, test.err);
+		}
+	}
+
+	if err := ValidateOutput([]byte(`{ "City": "Falun", `)); err == nil {
+		t.Errorf("invalid JSON accepted");
+	}
+}
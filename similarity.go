@@ -0,0 +1,150 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Fuzzy matching of restaurant names and ids (normalized Levenshtein
+    distance and token sets), linking a restaurant to its stable id when
+    its logo file is renamed or it comes from another provider. Uncertain
+    matches are warned about, and can be confirmed with an alias in the
+    configuration.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Similarity from which two names are taken to be the same restaurant,
+// and from which an uncertain match is warned about
+//
+const matchThreshold = 0.85;
+const warnThreshold = 0.6;
+
+// Words many names start with, which say nothing about which restaurant
+// it is and would make "restaurang-x" as similar to "restaurang-y" as x
+// to y is not
+//
+var namePrefixes = []string { "restaurang-", "restaurant-", "cafe-", "kafe-", "bistro-" };
+
+// Returns the similarity of two names between 0 and 1, the best of the
+// normalized Levenshtein distance and the overlap of their words.
+// Case, Swedish characters, punctuation and the common prefixes are
+// ignored.
+//
+func Similarity(a string, b string) float64 {
+	a, b = stripPrefix(Slug(a)), stripPrefix(Slug(b));
+	if a == "" || b == "" {
+		return 0;
+	}
+
+	var ra, rb = []int(a), []int(b);
+	var longest = len(ra);
+	if len(rb) > longest {
+		longest = len(rb);
+	}
+	var edit = 1 - float64(Levenshtein(ra, rb)) / float64(longest);
+
+	var ta, tb = strings.Split(a, "-", -1), strings.Split(b, "-", -1);
+	var common = 0;
+	for i := 0; i < len(ta); i++ {
+		if contains(tb, ta[i]) {
+			common++;
+		}
+	}
+	var tokens = float64(common) / float64(len(ta) + len(tb) - common);
+
+	if tokens > edit {
+		return tokens;
+	}
+	return edit;
+}
+
+// Returns the slug without the first of the common prefixes it starts
+// with, unless that's all there is
+//
+func stripPrefix(slug string) string {
+	for i := 0; i < len(namePrefixes); i++ {
+		if strings.HasPrefix(slug, namePrefixes[i]) && len(slug) > len(namePrefixes[i]) {
+			return slug[len(namePrefixes[i]):];
+		}
+	}
+	return slug;
+}
+
+// Returns the number of single character edits between two strings
+//
+func Levenshtein(a []int, b []int) int {
+	var previous = make([]int, len(b) + 1);
+	var current = make([]int, len(b) + 1);
+	for j := 0; j <= len(b); j++ {
+		previous[j] = j;
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i;
+		for j := 1; j <= len(b); j++ {
+			var cost = 1;
+			if a[i - 1] == b[j - 1] {
+				cost = 0;
+			}
+			current[j] = previous[j - 1] + cost;
+			if previous[j] + 1 < current[j] {
+				current[j] = previous[j] + 1;
+			}
+			if current[j - 1] + 1 < current[j] {
+				current[j] = current[j - 1] + 1;
+			}
+		}
+		previous, current = current, previous;
+	}
+	return previous[len(b)];
+}
+
+// Gives the restaurants not seen before the id of a known restaurant
+// that is very similar, and warns about uncertain matches. Aliases in
+// the configuration are applied first. Restaurants linked to the same id
+// need to be merged again with MergeDuplicates.
+//
+func LinkIds(list []RestData, day int, history map[int]*DataStruct) {
+	var known = make(map[string]bool);
+	for _, data := range history {
+		for d := 0; d < 5; d++ {
+			var restaurants = append(data.Days[d].Restaurants, data.Days[d].Specials...);
+			for i := 0; i < len(restaurants); i++ {
+				known[restaurants[i].Id] = true;
+			}
+		}
+	}
+
+	for i := 0; i < len(list); i++ {
+		var r = &list[i];
+		if alias, found := config.Aliases[r.Id]; found {
+			r.Id = alias;
+		}
+		if len(known) == 0 || known[r.Id] {
+			continue;
+		}
+
+		// Ties go to the first id in order, not to whichever the map
+		// happens to give first
+		//
+		var best, bestId = 0.0, "";
+		for id, _ := range known {
+			if s := Similarity(r.Id, id); s > best || s == best && s > 0 && id < bestId {
+				best, bestId = s, id;
+			}
+		}
+
+		switch {
+		case best >= matchThreshold:
+			fmt.Printf("Linked %s to %s (similarity %.2f)\n", r.Id, bestId, best);
+			r.Id = bestId;
+		case best >= warnThreshold:
+			Warn("possible-match", day, r.Id, fmt.Sprintf("%s might be %s (similarity %.2f), add an alias to confirm", r.Id, bestId, best));
+		}
+	}
+}
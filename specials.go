@@ -0,0 +1,83 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Detection of seasonal and other special menus (julbord, påskbuffé etc)
+    so they can be highlighted separately from the daily lunch.
+*/
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Markers of special menus and the label they are flagged with. The
+// text is lowercased and has its HTML entities decoded before matching.
+//
+type specialMarker struct {
+	rx *regexp.Regexp;
+	label string;
+}
+
+var specialMarkers = []specialMarker {
+	specialMarker{ regexp.MustCompile("julbord|jultallrik|julbuff"),		"julbord" },
+	specialMarker{ regexp.MustCompile("påskbuff|påskbord|påsklunch"),		"påskbuffé" },
+	specialMarker{ regexp.MustCompile("midsommarbuff|midsommarbord|midsommarlunch"),	"midsommar" },
+	specialMarker{ regexp.MustCompile("kräftskiva|kräftbuff"),			"kräftskiva" },
+	specialMarker{ regexp.MustCompile("sillbuff|sillbord"),				"sillbuffé" },
+	specialMarker{ regexp.MustCompile("mårtensafton|mårten gås|gåsmiddag"),		"mårten gås" },
+	specialMarker{ regexp.MustCompile("surströmming"),				"surströmming" },
+};
+
+// Returns the label of the first special menu found in the text, or an
+// empty string if it's an ordinary lunch menu
+//
+func DetectSpecial(text string) string {
+	text = strings.ToLower(DecodeEntities(text));
+
+	for i := 0; i < len(specialMarkers); i++ {
+		if specialMarkers[i].rx.MatchString(text) {
+			return specialMarkers[i].label;
+		}
+	}
+	return "";
+}
+
+// Moves all restaurants with a special menu from the restaurant list to
+// the separate list of specials of the day
+//
+func SplitSpecials(day *DayData) {
+	var restaurants = make([]RestData, 0, len(day.Restaurants));
+
+	for i := 0; i < len(day.Restaurants); i++ {
+		if day.Restaurants[i].Special != "" {
+			day.Specials = append(day.Specials, day.Restaurants[i]);
+		} else {
+			restaurants = append(restaurants, day.Restaurants[i]);
+		}
+	}
+	day.Restaurants = restaurants;
+}
+
+// Replaces the HTML entities used for Swedish characters on Lunchguiden
+// with the real characters
+//
+func DecodeEntities(text string) string {
+	var entities = [][]string {
+		[]string { "&aring;", "å" }, []string { "&Aring;", "Å" },
+		[]string { "&auml;", "ä" },  []string { "&Auml;", "Ä" },
+		[]string { "&ouml;", "ö" },  []string { "&Ouml;", "Ö" },
+		[]string { "&eacute;", "é" }, []string { "&Eacute;", "É" },
+		[]string { "&uuml;", "ü" },  []string { "&nbsp;", " " },
+		[]string { "&amp;", "&" },
+	};
+
+	for i := 0; i < len(entities); i++ {
+		text = strings.Replace(text, entities[i][0], entities[i][1], -1);
+	}
+	return text;
+}
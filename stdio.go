@@ -0,0 +1,75 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Reading from stdin and writing to stdout, for use in pipelines:
+
+      lunchguiden parse - < page.html > day.json
+      lunchguiden -url ... -city ... -week ... -out - | gzip > week.json.gz
+
+    When the output goes to stdout the progress messages go to stderr so
+    that they don't end up in the JSON data.
+*/
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"json"
+	"os"
+)
+
+// The real stdout, os.Stdout is stderr after RedirectMessages
+//
+var stdout = os.Stdout;
+
+// Sends everything printed with fmt.Print* to stderr instead of stdout
+//
+func RedirectMessages() {
+	os.Stdout = os.Stderr;
+}
+
+// Reads a file, or stdin for "-"
+//
+func ReadInput(name string) ([]byte, os.Error) {
+	if name == "-" {
+		return ioutil.ReadAll(os.Stdin);
+	}
+	return ioutil.ReadFile(name);
+}
+
+// Writes a file, or to stdout for "-"
+//
+func WriteOutput(name string, data []byte) os.Error {
+	if name == "-" {
+		_, err := stdout.Write(data);
+		return err;
+	}
+	return WriteFile(name, data);
+}
+
+// Parses one HTML document of a day, from a file or stdin, and writes
+// the day as JSON to a file or stdout. Nothing is downloaded except for
+// the image checks.
+//
+func ParseFilter(in string, out string, week int) os.Error {
+	inData, err := ReadInput(in);
+	if err != nil {
+		return err;
+	}
+
+	var day DayData;
+	ParseDay(&day, "", int64(*year), week, inData, nil);
+
+	jsonOutput, err := json.Marshal(day);
+	if err != nil {
+		return err;
+	}
+	var output = bytes.NewBuffer(make([]byte, 0));
+	json.Indent(output, jsonOutput, "", "");
+	output.WriteString("\n");
+	return WriteOutput(out, output.Bytes());
+}
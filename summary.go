@@ -0,0 +1,64 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Summary statistics of a week, precomputed for the week overview of
+    the app.
+*/
+
+package main
+
+type Summary struct {
+	Restaurants int;		// Distinct restaurants during the week
+	DishesPerDay float64;		// Average over the days with restaurants
+	CheapestOre int;		// Lowest and highest price seen, 0 if none
+	DearestOre int;
+	Vegetarian [5]int;		// Vegetarian dishes per day
+}
+
+// Computes the summary of the week
+//
+func Summarize(data *DataStruct) *Summary {
+	var summary = new(Summary);
+	var seen = make(map[string]bool);
+	var dishes, days = 0, 0;
+
+	for day := 0; day < 5; day++ {
+		var list = append(data.Days[day].Restaurants, data.Days[day].Specials...);
+		if len(list) > 0 {
+			days++;
+		}
+
+		for i := 0; i < len(list); i++ {
+			seen[list[i].Id] = true;
+			dishes += len(list[i].Dishes);
+
+			for j := 0; j < len(list[i].Dishes); j++ {
+				var dish = &list[i].Dishes[j];
+				if contains(dish.Categories, "vegetarian") {
+					summary.Vegetarian[day]++;
+				}
+				for k := 0; k < len(dish.PriceOre); k++ {
+					var ore = dish.PriceOre[k];
+					if ore <= 0 {
+						continue;
+					}
+					if summary.CheapestOre == 0 || ore < summary.CheapestOre {
+						summary.CheapestOre = ore;
+					}
+					if ore > summary.DearestOre {
+						summary.DearestOre = ore;
+					}
+				}
+			}
+		}
+	}
+
+	summary.Restaurants = len(seen);
+	if days > 0 {
+		summary.DishesPerDay = float64(int(float64(dishes) / float64(days) * 10 + 0.5)) / 10;
+	}
+	return summary;
+}
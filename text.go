@@ -0,0 +1,361 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Sanitization profiles for the descriptions and menus (-text), so every
+    consumer gets the text in the shape it needs: raw as parsed, plain
+    text, limited HTML or markdown. Length limits for them, protecting the
+    app from the odd restaurant pasting its whole menu (-max-menu,
+    -max-description).
+
+    Whatever the profile, all text of the output is cut down to a few
+    formatting tags and plain links before it's written, since the web
+    site and the web views of the app put the texts in their pages as
+    they are.
+*/
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var rx_spaces = regexp.MustCompile("[ \t]+");
+
+// Tags kept in the texts and the attributes each of them may keep, all
+// other tags are removed and their contents kept
+//
+var allowedTags = map[string][]string {
+	"b":		[]string {},
+	"strong":	[]string {},
+	"i":		[]string {},
+	"em":		[]string {},
+	"u":		[]string {},
+	"small":	[]string {},
+	"sub":		[]string {},
+	"sup":		[]string {},
+	"br":		[]string {},
+	"p":		[]string {},
+	"span":		[]string {},
+	"ul":		[]string {},
+	"ol":		[]string {},
+	"li":		[]string {},
+	"a":		[]string { "href", "title" },
+};
+
+// Schemes of the links kept, links without a scheme are kept as well
+//
+var allowedSchemes = []string { "http:", "https:", "mailto:" };
+
+// Elements removed with their contents, which is code, styles or other
+// documents rather than text. Elements that never have contents, like
+// embed and frame, are removed like any other tag.
+//
+var activeElements = map[string]bool {
+	"script": true, "style": true, "iframe": true, "object": true,
+	"applet": true, "noscript": true, "template": true,
+	"textarea": true, "title": true, "xmp": true, "svg": true, "math": true,
+};
+
+var rx_charref = regexp.MustCompile("&#([xX][0-9a-fA-F]+|[0-9]+);?");
+
+// Tells if the name is a known text profile
+//
+func ValidTextProfile(profile string) bool {
+	return profile == "raw" || profile == "plain" || profile == "html" || profile == "markdown";
+}
+
+// Applies the text profile to the descriptions and menus of the
+// restaurants
+//
+func SanitizeRestaurants(list []RestData, profile string) {
+	if profile == "raw" {
+		return;
+	}
+	for i := 0; i < len(list); i++ {
+		list[i].Description = SanitizeText(list[i].Description, profile);
+		list[i].Menu = SanitizeText(list[i].Menu, profile);
+	}
+}
+
+// Returns the text in the shape of the profile. All profiles but raw
+// decode the entities, collapse the whitespace left by tag stripping
+// and drop empty lines.
+//
+func SanitizeText(text string, profile string) string {
+	if profile == "raw" {
+		return text;
+	}
+
+	text = rx_html.ReplaceAllString(DecodeEntities(text), " ");
+	var lines = strings.Split(text, "\n", -1);
+	var result = make([]string, 0, len(lines));
+
+	for i := 0; i < len(lines); i++ {
+		var line = strings.TrimSpace(rx_spaces.ReplaceAllString(lines[i], " "));
+		if line == "" {
+			continue;
+		}
+
+		// List items are marked with "* " by the parser
+		//
+		var item = strings.HasPrefix(line, "* ");
+		if item {
+			line = line[2:];
+		}
+
+		switch profile {
+		case "html":
+			line = escapeHTML(line);
+			if item {
+				line = "&bull; " + line;
+			}
+		case "markdown":
+			line = escapeMarkdown(line);
+			if item {
+				line = "- " + line;
+			}
+		default:
+			if item {
+				line = "* " + line;
+			}
+		}
+		result = append(result, line);
+	}
+
+	if profile == "html" {
+		return strings.Join(result, "<br>");
+	}
+	return strings.Join(result, "\n");
+}
+
+// Cuts all texts of the week down to the allowed tags: the names,
+// descriptions, menus, dishes and notes of the restaurants, and the
+// warnings. Image links that aren't http or embedded images are dropped.
+//
+func SanitizeWeek(data *DataStruct) {
+	for day := 0; day < 5; day++ {
+		var d = &data.Days[day];
+		d.Name = StripActive(d.Name);
+		d.Holiday = StripActive(d.Holiday);
+		for _, list := range [][]RestData{ d.Restaurants, d.Specials } {
+			for i := 0; i < len(list); i++ {
+				var r = &list[i];
+				r.Name = StripActive(r.Name);
+				r.Description = StripActive(r.Description);
+				r.Menu = StripActive(r.Menu);
+				r.ClosedNote = StripActive(r.ClosedNote);
+				for j := 0; j < len(r.Dishes); j++ {
+					r.Dishes[j].Text = StripActive(r.Dishes[j].Text);
+					r.Dishes[j].Price = StripActive(r.Dishes[j].Price);
+				}
+				if r.ImageUrl != "" && !strings.HasPrefix(r.ImageUrl, "http://") && !strings.HasPrefix(r.ImageUrl, "https://") && !strings.HasPrefix(r.ImageUrl, "data:image/") {
+					r.ImageUrl = "";
+				}
+			}
+		}
+	}
+	for i := 0; i < len(data.Warnings); i++ {
+		data.Warnings[i].Text = StripActive(data.Warnings[i].Text);
+	}
+}
+
+// Returns the text with only the allowed tags and attributes left, and
+// without the elements that hold code. Tags are written again from what
+// was kept of them, so nothing but the text is passed through as it was.
+// An element written empty, like <svg/>, has no contents to remove.
+//
+func StripActive(text string) string {
+	if strings.Index(text, "<") < 0 {
+		return text;
+	}
+
+	var result bytes.Buffer;
+	var skip = "";
+	for _, t := range tokenize(text) {
+		switch {
+		case skip != "":
+			if t.tag == "/" + skip {
+				skip = "";
+			}
+		case t.tag == "":
+			result.WriteString(t.text);
+		case activeElements[t.tag]:
+			if !t.closed {
+				skip = t.tag;
+			}
+		default:
+			result.WriteString(allowedTag(t));
+		}
+	}
+	return result.String();
+}
+
+// Returns the tag written again with its allowed attributes, empty if
+// the tag isn't allowed. Links are only kept with an allowed scheme.
+//
+func allowedTag(t token) string {
+	var name = strings.TrimLeft(t.tag, "/");
+	attrs, ok := allowedTags[name];
+	if !ok {
+		return "";
+	}
+	if name != t.tag {
+		return "</" + name + ">";
+	}
+
+	var tag = "<" + name;
+	for i := 0; i < len(attrs); i++ {
+		value, ok := t.attrs[attrs[i]];
+		if !ok {
+			continue;
+		}
+		value = decodeAttribute(value);
+		if attrs[i] == "href" && !safeLink(value) {
+			continue;
+		}
+		tag += " " + attrs[i] + "=\"" + escapeHTML(value) + "\"";
+	}
+	return tag + ">";
+}
+
+// Decodes the character references and entities of an attribute value
+// the way a browser would before following it, so &#106;avascript: is
+// seen for what it is
+//
+func decodeAttribute(value string) string {
+	value = rx_charref.ReplaceAllStringFunc(value, func(ref string) string {
+		var digits = ref[2:];
+		if strings.HasSuffix(digits, ";") {
+			digits = digits[0:len(digits) - 1];
+		}
+		var n uint64;
+		var err os.Error;
+		if digits[0] == 'x' || digits[0] == 'X' {
+			n, err = strconv.Btoui64(digits[1:], 16);
+		} else {
+			n, err = strconv.Btoui64(digits, 10);
+		}
+		if err != nil || n == 0 || n > 0x10FFFF {
+			return "\uFFFD";
+		}
+		return string(int(n));
+	});
+
+	value = strings.Replace(value, "&colon;", ":", -1);
+	value = strings.Replace(value, "&Tab;", "\t", -1);
+	value = strings.Replace(value, "&NewLine;", "\n", -1);
+	return DecodeEntities(value);
+}
+
+// Tells if a decoded link has one of the allowed schemes, or no scheme.
+// Browsers ignore whitespace and control characters in the scheme, and
+// an entity left undecoded may hide one.
+//
+func safeLink(link string) bool {
+	var compact = make([]byte, 0, len(link));
+	for i := 0; i < len(link); i++ {
+		if link[i] > ' ' {
+			compact = append(compact, link[i]);
+		}
+	}
+	var lower = asciiLower(string(compact));
+
+	for i := 0; i < len(allowedSchemes); i++ {
+		if strings.HasPrefix(lower, allowedSchemes[i]) {
+			return true;
+		}
+	}
+
+	var scheme = lower;
+	for i := 0; i < len(lower); i++ {
+		if lower[i] == '/' || lower[i] == '?' || lower[i] == '#' {
+			scheme = lower[0:i];
+			break;
+		}
+	}
+	return strings.Index(scheme, ":") < 0 && strings.Index(scheme, "&") < 0;
+}
+
+// Lowercases the ASCII letters only, so the indexes stay the same as in
+// the original text
+//
+func asciiLower(text string) string {
+	var lower = []byte(text);
+	for i := 0; i < len(lower); i++ {
+		if lower[i] >= 'A' && lower[i] <= 'Z' {
+			lower[i] += 'a' - 'A';
+		}
+	}
+	return string(lower);
+}
+
+// Escapes the characters with a meaning in HTML
+//
+func escapeHTML(text string) string {
+	text = strings.Replace(text, "&", "&amp;", -1);
+	text = strings.Replace(text, "<", "&lt;", -1);
+	text = strings.Replace(text, ">", "&gt;", -1);
+	return strings.Replace(text, "\"", "&quot;", -1);
+}
+
+// Escapes the characters with a meaning in markdown
+//
+func escapeMarkdown(text string) string {
+	var special = []string { "\\", "*", "_", "`", "[", "]", "#" };
+	for i := 0; i < len(special); i++ {
+		text = strings.Replace(text, special[i], "\\" + special[i], -1);
+	}
+	return text;
+}
+
+// Shortens the descriptions and menus longer than the limits, in
+// characters, and flags the restaurants as truncated. A limit of 0
+// means no limit.
+//
+func TruncateRestaurants(list []RestData, maxMenu int, maxDescription int) {
+	for i := 0; i < len(list); i++ {
+		var r = &list[i];
+		var cut bool;
+
+		if r.Menu, cut = Truncate(r.Menu, maxMenu); cut {
+			r.Truncated = true;
+		}
+		if r.Description, cut = Truncate(r.Description, maxDescription); cut {
+			r.Truncated = true;
+		}
+	}
+}
+
+// Returns the text shortened to at most max characters, ellipsis
+// included, cut at a space if there is one near the end. Entities and
+// tags are never cut in half.
+//
+func Truncate(text string, max int) (string, bool) {
+	var runes = []int(text);
+	if max <= 0 || len(runes) <= max {
+		return text, false;
+	}
+
+	var cut = string(runes[0:max - 1]);
+	var space = strings.LastIndex(cut, " ");
+	if i := strings.LastIndex(cut, "\n"); i > space {
+		space = i;
+	}
+	if space > len(cut) * 3 / 4 {
+		cut = cut[0:space];
+	}
+	if i := strings.LastIndex(cut, "&"); i > strings.LastIndex(cut, ";") {
+		cut = cut[0:i];
+	}
+	if i := strings.LastIndex(cut, "<"); i > strings.LastIndex(cut, ">") {
+		cut = cut[0:i];
+	}
+	return strings.TrimSpace(cut) + "…", true;
+}
@@ -0,0 +1,91 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Tests of the sanitization of the texts against hostile markup.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+var stripTests = []struct {
+	in, out string;
+} {
+	// Markup that is kept
+	//
+	{ "Dagens <b>lax</b> med potatis", "Dagens <b>lax</b> med potatis" },
+	{ "Pasta<br/>Sallad<BR>", "Pasta<br>Sallad<br>" },
+	{ "Pris < 100 kr", "Pris < 100 kr" },
+	{ "<a href=\"http://example.com/?a=1&amp;b=2\" onmouseover=\"x()\">Meny</a>", "<a href=\"http://example.com/?a=1&amp;b=2\">Meny</a>" },
+	{ "<a href=\"/meny\" title='Veckans'>Meny</a>", "<a href=\"/meny\" title=\"Veckans\">Meny</a>" },
+
+	// Code and the elements holding it
+	//
+	{ "<script>alert(1)</script>Pasta", "Pasta" },
+	{ "<SCRIPT src=\"http://evil/x.js\"></SCRIPT>Pasta", "Pasta" },
+	{ "<svg><script>alert(1)</script></svg>Fisk", "Fisk" },
+	{ "Fisk<script>alert(1)", "Fisk" },
+	{ "<!-- <script>alert(1)</script> -->Fisk", "Fisk" },
+	{ "<b onclick=\"alert(1)\">Dagens</b>", "<b>Dagens</b>" },
+	{ "<img src=x onerror=alert(1)>Soppa", "Soppa" },
+	{ "Pasta <b", "Pasta " },
+
+	// Links to code
+	//
+	{ "<a href=\"javascript:alert(1)\">x</a>", "<a>x</a>" },
+	{ "<a href=\"JaVaScRiPt:alert(1)\">x</a>", "<a>x</a>" },
+	{ "<a href=\"&#106;avascript:alert(1)\">x</a>", "<a>x</a>" },
+	{ "<a href=\"&#x6A;avascript&colon;alert(1)\">x</a>", "<a>x</a>" },
+	{ "<a href=\"&#0000106&#0000097vascript:alert(1)\">x</a>", "<a>x</a>" },
+	{ "<a href=\"java&Tab;script:alert(1)\">x</a>", "<a>x</a>" },
+	{ "<a href=\"java\tscript:alert(1)\">x</a>", "<a>x</a>" },
+	{ "<a href=\"javascript&unknown;:alert(1)\">x</a>", "<a>x</a>" },
+	{ "<a href=\"data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==\">x</a>", "<a>x</a>" },
+	{ "<a href=\"vbscript:msgbox(1)\">x</a>", "<a>x</a>" },
+
+	// Tags changing the page the text is put in
+	//
+	{ "<meta http-equiv=\"refresh\" content=\"0;url=http://evil/\">Soppa", "Soppa" },
+	{ "<base href=\"http://evil/\">Soppa", "Soppa" },
+	{ "<link rel=\"stylesheet\" href=\"http://evil/x.css\">Soppa", "Soppa" },
+	{ "<style>body { display: none }</style>Soppa", "Soppa" },
+	{ "<iframe src=\"http://evil/\"></iframe>Soppa", "Soppa" },
+	{ "<form action=\"http://evil/\"><input name=\"q\"></form>Soppa", "Soppa" },
+
+	// Elements without contents only lose their tag
+	//
+	{ "<iframe src=\"http://evil/\"/>Soppa", "Soppa" },
+	{ "<svg/>Fisk <b>och</b> skaldjur", "Fisk <b>och</b> skaldjur" },
+	{ "<embed src=\"http://evil/x.swf\">Soppa", "Soppa" },
+	{ "<frame src=\"http://evil/\">Soppa", "Soppa" },
+	{ "<svg/><script>alert(1)</script>Soppa", "Soppa" },
+};
+
+func TestStripActive(t *testing.T) {
+	for _, test := range stripTests {
+		var out = StripActive(test.in);
+		if out != test.out {
+			t.Errorf("StripActive(%q) = %q, want %q", test.in, out, test.out);
+		}
+	}
+}
+
+func TestSanitizeWeek(t *testing.T) {
+	var data = new(DataStruct);
+	data.Days[0].Restaurants = []RestData{ RestData{
+		Name: "<script>alert(1)</script>Kajutan",
+		Menu: "<a href=\"javascript:alert(1)\">Lax</a>",
+		ImageUrl: "javascript:alert(1)",
+	} };
+	SanitizeWeek(data);
+
+	var r = data.Days[0].Restaurants[0];
+	if r.Name != "Kajutan" || r.Menu != "<a>Lax</a>" || r.ImageUrl != "" {
+		t.Errorf("SanitizeWeek left %q, %q, %q", r.Name, r.Menu, r.ImageUrl);
+	}
+}
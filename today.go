@@ -0,0 +1,91 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Refresh of today's menus only (-today), merged into the week in the
+    archive, for the restaurants that update their menu late in the
+    morning. The archive has the whole week, the published file may be
+    trimmed to -max-bytes.
+*/
+
+package main
+
+import (
+	"fmt"
+	"json"
+	"os"
+)
+
+// Returns a copy of a week, to change without changing the original
+//
+func CopyWeek(data *DataStruct) (*DataStruct, os.Error) {
+	stored, err := json.Marshal(data);
+	if err != nil {
+		return nil, err;
+	}
+	var week = new(DataStruct);
+	if err = json.Unmarshal(stored, week); err != nil {
+		return nil, err;
+	}
+	return week, nil;
+}
+
+// Replaces the day in the published week with the refreshed one and
+// returns the ids of the restaurants whose menu changed. The warnings
+// of the other days are kept. The menus left out with -dedup-weekly are
+// put back before comparing and only left out again at the end.
+//
+func MergeDay(published *DataStruct, refreshed *DataStruct, day int) []string {
+	UnmarkWeeklyMenus(published);
+	UnmarkWeeklyMenus(refreshed);
+
+	var before = make(map[string]string);
+	var list = append(published.Days[day].Restaurants, published.Days[day].Specials...);
+	for i := 0; i < len(list); i++ {
+		before[list[i].Id] = list[i].Menu;
+	}
+
+	var changed = make([]string, 0);
+	list = append(refreshed.Days[day].Restaurants, refreshed.Days[day].Specials...);
+	for i := 0; i < len(list); i++ {
+		if menu, found := before[list[i].Id]; !found || menu != list[i].Menu {
+			changed = append(changed, list[i].Id);
+		}
+		before[list[i].Id] = "", false;
+	}
+	for id, _ := range before {
+		changed = append(changed, id);		// Gone since the last run
+	}
+
+	var kept = make([]Warning, 0, len(published.Warnings));
+	for i := 0; i < len(published.Warnings); i++ {
+		if published.Warnings[i].Day != day {
+			kept = append(kept, published.Warnings[i]);
+		}
+	}
+	warnings = append(kept, warnings...);
+
+	published.Days[day] = refreshed.Days[day];
+	published.Generator = refreshed.Generator;
+	published.Dropped = nil;
+	published.Summary = Summarize(published);
+	MarkWeeklyMenus(published, *dedupWeekly);
+	return changed;
+}
+
+// Prints the restaurants changed by the refresh and records them in the
+// changelog of the archive
+//
+func ReportChanges(archiveDir string, data *DataStruct, day int, changed []string) {
+	fmt.Printf("%d restaurants changed on %s\n", len(changed), data.Days[day].Name);
+	for i := 0; i < len(changed); i++ {
+		var text = fmt.Sprintf("menu of %s changed on %s", changed[i], data.Days[day].Name);
+		if archiveDir != "" {
+			AppendChangelog(archiveDir, data, text);
+		} else {
+			fmt.Printf("  %s\n", text);
+		}
+	}
+}
@@ -0,0 +1,67 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Tests of the refresh of today's menus.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+// Returns a week with the weekly menu of a on all days and the menus of
+// b, or nothing for an empty menu
+//
+func todayWeek(b []string) *DataStruct {
+	var data = new(DataStruct);
+	for day := 0; day < 5; day++ {
+		data.Days[day].Day = day;
+		data.Days[day].Restaurants = []RestData{ RestData{ Id: "a", Menu: "Pasta carbonara" } };
+		if b[day] != "" {
+			data.Days[day].Restaurants = append(data.Days[day].Restaurants, RestData{ Id: "b", Menu: b[day] });
+		}
+	}
+	return data;
+}
+
+func TestMergeDay(t *testing.T) {
+	var dedup = *dedupWeekly;
+	*dedupWeekly = true;
+	defer func() { *dedupWeekly = dedup }();
+
+	var published = todayWeek([]string { "Fisk", "Köttbullar", "Soppa", "Pytt", "Lax" });
+	MarkWeeklyMenus(published, true);
+	if published.Days[1].Restaurants[0].Menu != "" {
+		t.Fatalf("weekly menu of a not left out on Tuesday");
+	}
+
+	var refreshed = todayWeek([]string { "", "Kålpudding", "", "", "" });
+	for day := 0; day < 5; day++ {
+		if day != 1 {
+			refreshed.Days[day].Restaurants = nil;
+		}
+	}
+
+	var changed = MergeDay(published, refreshed, 1);
+	if len(changed) != 1 || changed[0] != "b" {
+		t.Errorf("changed is %v, want [b]", changed);
+	}
+
+	var monday, tuesday = published.Days[0].Restaurants[0], published.Days[1].Restaurants[0];
+	if monday.Menu != "Pasta carbonara" || !monday.WeeklyMenu {
+		t.Errorf("Monday of a is %q, weekly %v", monday.Menu, monday.WeeklyMenu);
+	}
+	if tuesday.Menu != "" || !tuesday.WeeklyMenu {
+		t.Errorf("Tuesday of a is %q, weekly %v, want it left out", tuesday.Menu, tuesday.WeeklyMenu);
+	}
+	if menu := published.Days[1].Restaurants[1].Menu; menu != "Kålpudding" {
+		t.Errorf("Tuesday of b is %q, want Kålpudding", menu);
+	}
+	if menu := published.Days[2].Restaurants[1].Menu; menu != "Soppa" {
+		t.Errorf("Wednesday of b is %q, want Soppa", menu);
+	}
+}
@@ -0,0 +1,114 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Setup of the HTTP transport used for all requests, the one of the
+    client package (client/transport.go), from the flags.
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"http"
+	"io/ioutil"
+	"lunchguiden/client"
+	"net"
+	"os"
+	"strings"
+)
+
+const userAgent = "lunchguiden (+https://github.com/rickard2/lunchguiden)";
+
+// Most redirects followed for one request
+//
+const maxRedirects = 5;
+
+// TLS settings for https URLs, set up from the flags by SetupTLS
+//
+var tlsConfig = new(tls.Config);
+
+// The transport of all requests. The network and local address of the
+// outbound connections are set up from the flags by SetupNetwork.
+//
+var transport = &client.Transport{ TLSConfig: tlsConfig, Network: "tcp", UserAgent: userAgent, MaxRedirects: maxRedirects };
+
+// Forces IPv4 ("4") or IPv6 ("6") for the connections, or leaves the
+// choice to the resolver ("any"), and binds them to a source address
+//
+func SetupNetwork(ipVersion string, source string) os.Error {
+	switch ipVersion {
+	case "", "any":
+		transport.Network = "tcp";
+	case "4":
+		transport.Network = "tcp4";
+	case "6":
+		transport.Network = "tcp6";
+	default:
+		return os.NewError("unknown IP version " + ipVersion);
+	}
+
+	if source != "" {
+		var ip = net.ParseIP(source);
+		if ip == nil {
+			return os.NewError("invalid source address " + source);
+		}
+		if strings.Index(source, ":") >= 0 {
+			if transport.Network == "tcp4" {
+				return os.NewError("IPv6 source address with -ip=4");
+			}
+			transport.LocalAddr = "[" + source + "]:0";
+		} else {
+			if transport.Network == "tcp6" {
+				return os.NewError("IPv4 source address with -ip=6");
+			}
+			transport.LocalAddr = source + ":0";
+		}
+	}
+	return nil;
+}
+
+// Sets up the TLS settings: an optional PEM file with the certificates
+// of the CAs to trust instead of the system ones and if certificates
+// should be verified at all
+//
+func SetupTLS(caFile string, insecure bool) os.Error {
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile);
+		if err != nil {
+			return err;
+		}
+		var set = tls.NewCASet();
+		if !set.SetFromPEM(pem) {
+			return os.NewError("no certificates found in " + caFile);
+		}
+		tlsConfig.RootCAs = set;
+	}
+
+	if insecure {
+		fmt.Println("WARNING: TLS certificates are NOT verified (-insecure)");
+		tlsConfig.InsecureSkipVerify = true;
+	}
+	return nil;
+}
+
+// Makes the request with the transport and returns the response,
+// following redirects for GET and HEAD requests. The body of the
+// response must be closed.
+//
+func Do(r *Request) (*http.Response, os.Error) {
+	var header = make(map[string]string);
+	if r.Method == "POST" {
+		header["Content-Type"] = r.ContentType;
+	}
+
+	// The configuration may turn compression off, it's read after the
+	// transport is set up
+	//
+	var t = *transport;
+	t.Compressed = Enabled("compressed_downloads");
+	return t.Do(r.Method, r.Url, header, r.Body);
+}
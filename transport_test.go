@@ -0,0 +1,94 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Tests of the HTTP client against a local server sending canned
+    responses.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testDocument = "<html><body><table><tr><td>Dagens lunch</td></tr></table></body></html>";
+
+// Serves one request on a local port with the body and Content-Encoding
+// and returns the URL of the server
+//
+func serveOnce(t *testing.T, encoding string, body []byte) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0");
+	if err != nil {
+		t.Fatalf("listen: %s", err);
+	}
+	go func() {
+		defer l.Close();
+		conn, err := l.Accept();
+		if err != nil {
+			return;
+		}
+		defer conn.Close();
+
+		var r = bufio.NewReader(conn);
+		for {
+			line, err := r.ReadString('\n');
+			if err != nil || line == "\r\n" {
+				break;
+			}
+		}
+		fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=utf-8\r\n");
+		if encoding != "" {
+			fmt.Fprintf(conn, "Content-Encoding: %s\r\n", encoding);
+		}
+		fmt.Fprintf(conn, "Content-Length: %d\r\n\r\n", len(body));
+		conn.Write(body);
+	}();
+	return "http://" + l.Addr().String() + "/lunch.asp";
+}
+
+func compressed(t *testing.T, encoding string) []byte {
+	var buf = bytes.NewBuffer(make([]byte, 0));
+	var w io.WriteCloser;
+	var err os.Error;
+	if encoding == "gzip" {
+		w, err = gzip.NewWriter(buf);
+	} else {
+		w, err = zlib.NewWriter(buf);
+	}
+	if err != nil {
+		t.Fatalf("%s writer: %s", encoding, err);
+	}
+	w.Write([]byte(testDocument));
+	w.Close();
+	return buf.Bytes();
+}
+
+func TestFetchDecodesCompressedResponses(t *testing.T) {
+	for _, encoding := range []string{ "", "gzip", "deflate" } {
+		var body = []byte(testDocument);
+		if encoding != "" {
+			body = compressed(t, encoding);
+		}
+
+		data, err := Fetch(serveOnce(t, encoding, body));
+		if err != nil {
+			t.Errorf("%q: %s", encoding, err);
+			continue;
+		}
+		if strings.TrimSpace(string(data)) != testDocument {
+			t.Errorf("%q: got %q", encoding, data);
+		}
+	}
+}
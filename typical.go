@@ -0,0 +1,83 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    The weekdays a restaurant usually has a menu on, learned from the
+    archive, so the app can gray out the days a restaurant is always
+    closed (like mondays) instead of showing that the menu is missing.
+*/
+
+package main
+
+// Fewest archived weeks a restaurant must appear in before its typical
+// days are given
+//
+const typicalMinWeeks = 4;
+
+// Sets the typical days of the restaurants of the week from the archived
+// weeks. A day is typical if the restaurant had a menu on it in at least
+// half of the weeks it appeared in, not counting holidays and days that
+// couldn't be downloaded.
+//
+func AddTypicalDays(data *DataStruct, history map[int]*DataStruct) {
+	var current = WeekKey(int64(data.Year), data.Week);
+	var weeks = make(map[string]int);
+	var menus = make(map[string]*[5]int);
+	var possible = make(map[string]*[5]int);
+
+	for key, archived := range history {
+		if key == current {
+			continue;
+		}
+
+		// Restaurants of the week, and the days they had a menu
+		//
+		var seen = make(map[string]*[5]bool);
+		for day := 0; day < 5; day++ {
+			var list = append(archived.Days[day].Restaurants, archived.Days[day].Specials...);
+			for i := 0; i < len(list); i++ {
+				if seen[list[i].Id] == nil {
+					seen[list[i].Id] = new([5]bool);
+				}
+				if list[i].Menu != "" && !list[i].Closed {
+					seen[list[i].Id][day] = true;
+				}
+			}
+		}
+
+		for id, days := range seen {
+			if menus[id] == nil {
+				menus[id], possible[id] = new([5]int), new([5]int);
+			}
+			weeks[id]++;
+			for day := 0; day < 5; day++ {
+				if archived.Days[day].Holiday != "" || archived.Days[day].Error != "" {
+					continue;
+				}
+				possible[id][day]++;
+				if days[day] {
+					menus[id][day]++;
+				}
+			}
+		}
+	}
+
+	for day := 0; day < 5; day++ {
+		for _, list := range [][]RestData{ data.Days[day].Restaurants, data.Days[day].Specials } {
+			for i := 0; i < len(list); i++ {
+				var id = list[i].Id;
+				if weeks[id] < typicalMinWeeks {
+					continue;
+				}
+				list[i].TypicalDays = make([]int, 0, 5);
+				for d := 0; d < 5; d++ {
+					if possible[id][d] > 0 && menus[id][d] * 2 >= possible[id][d] {
+						list[i].TypicalDays = append(list[i].TypicalDays, d);
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Verification of a published file on a mirror ("verify <url>"): the
+    md5 sum must match the .md5 file next to it and the data must match
+    the schema. A quick smoke test of the whole publishing chain.
+*/
+
+package main
+
+import (
+	"fmt"
+	"http"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Downloads a published file and its md5 sum and verifies them
+//
+func Verify(url string) os.Error {
+	data, err := download(url);
+	if err != nil {
+		return err;
+	}
+	fmt.Printf("Downloaded %d bytes from %s\n", len(data), url);
+
+	sum, err := download(url + ".md5");
+	if err != nil {
+		return err;
+	}
+	var hashStr, _ = GenerateHash(data);
+	if strings.TrimSpace(string(sum)) != hashStr {
+		return os.NewError(fmt.Sprintf("md5 mismatch, computed %s but %s.md5 says %s", hashStr, url, strings.TrimSpace(string(sum))));
+	}
+	fmt.Printf("MD5 OK: %s\n", hashStr);
+
+	if err = ValidateOutput(data); err != nil {
+		return err;
+	}
+	fmt.Printf("Schema OK: version %d\n", SchemaVersion);
+	return nil;
+}
+
+// Downloads the file as it is, without any character set conversion
+//
+func download(url string) ([]byte, os.Error) {
+	res, err := Do(&Request{ Method: "GET", Url: url });
+	if err != nil {
+		return nil, err;
+	}
+	defer res.Body.Close();
+
+	if res.StatusCode != http.StatusOK {
+		return nil, &StatusError{ url, res.Status };
+	}
+	return ioutil.ReadAll(res.Body);
+}
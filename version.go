@@ -0,0 +1,27 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Version and build information (-version), also written to the output
+    so any published file can be traced to the build that made it. The
+    commit and date are filled in by buildinfo.go, generated by make.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+const Version = "1.1.0";
+
+var buildCommit = "unknown";
+var buildDate = "unknown";
+
+// Returns the version, build and schema version as one line
+//
+func VersionString() string {
+	return fmt.Sprintf("lunchguiden %s (commit %s, built %s, schema %d)", Version, buildCommit, buildDate, SchemaVersion);
+}
@@ -0,0 +1,96 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Question and answer feed for the voice assistant (-voice), with one
+    answer per restaurant and weekday ("Vad serverar X på tisdag?"). The
+    texts are plain Swedish meant to be read out loud.
+*/
+
+package main
+
+import (
+	"fmt"
+	"json"
+	"os"
+	"strings"
+)
+
+type VoiceFeed struct {
+	City string;
+	Week int;
+	Entries []VoiceEntry;
+}
+type VoiceEntry struct {
+	Day int;
+	Restaurant string;		// Id of the restaurant
+	Question string;
+	Answer string;
+}
+
+// Writes the question and answer feed of the week to the file
+//
+func WriteVoiceFeed(file string, data *DataStruct) os.Error {
+	var feed = VoiceFeed{ City: data.City, Week: data.Week, Entries: make([]VoiceEntry, 0) };
+
+	for day := 0; day < 5; day++ {
+		var weekday = strings.ToLower(weekdayNames["sv"][day]);
+		var list = append(data.Days[day].Restaurants, data.Days[day].Specials...);
+
+		for i := 0; i < len(list); i++ {
+			var r = &list[i];
+			var name = spoken(r.Name);
+			if name == "" {
+				continue;		// Unknown restaurants can't be asked for
+			}
+
+			// Weekly menus may only be kept on Monday
+			//
+			var menu = WeeklyDishes(data, r);
+
+			var answer string;
+			switch {
+			case r.Closed:
+				answer = fmt.Sprintf("%s har stängt på %s.", name, weekday);
+			case len(menu) == 0:
+				answer = fmt.Sprintf("Jag hittar ingen meny för %s på %s.", name, weekday);
+			default:
+				var dishes = make([]string, len(menu));
+				for j := 0; j < len(menu); j++ {
+					dishes[j] = spoken(menu[j].Text);
+				}
+				answer = fmt.Sprintf("På %s serverar %s %s.", weekday, name, spokenList(dishes));
+			}
+
+			feed.Entries = append(feed.Entries, VoiceEntry{
+				Day:		day,
+				Restaurant:	r.Id,
+				Question:	fmt.Sprintf("Vad serverar %s på %s?", name, weekday),
+				Answer:		answer,
+			});
+		}
+	}
+
+	output, err := json.Marshal(feed);
+	if err != nil {
+		return err;
+	}
+	return WriteFile(file, output);
+}
+
+// Returns the text as plain text without markup, for reading out loud
+//
+func spoken(text string) string {
+	return strings.TrimSpace(strings.TrimRight(SanitizeText(text, "plain"), "."));
+}
+
+// Joins the parts as a Swedish enumeration: a, b och c
+//
+func spokenList(parts []string) string {
+	if len(parts) == 1 {
+		return parts[0];
+	}
+	return strings.Join(parts[0:len(parts) - 1], ", ") + " och " + parts[len(parts) - 1];
+}
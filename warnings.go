@@ -0,0 +1,55 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Collection of non-fatal data quality issues, which are included in the
+    output so they can be triaged without reading the logs.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Warning struct {
+	Kind string;			// unknown-logo, missing-description, empty-menu, truncated-html, missing-snapshot, possible-match or image-rejected
+	Day int;
+	Restaurant string;		// Id of the restaurant, empty for warnings about the whole day
+	Text string;
+}
+
+// All warnings of the run
+//
+var warnings = make([]Warning, 0);
+
+// Records a warning and prints it
+//
+func Warn(kind string, day int, restaurant string, text string) {
+	fmt.Printf("WARNING: %s\n", text);
+	warnings = append(warnings, Warning{ kind, day, restaurant, text });
+}
+
+// Checks the HTML document and the restaurants parsed from it for issues
+//
+func CheckDay(day int, inData []byte, restaurants []RestData) {
+	if strings.Index(strings.ToLower(string(inData)), "</html>") < 0 {
+		Warn("truncated-html", day, "", fmt.Sprintf("HTML document of day %d is truncated", day));
+	}
+
+	for i := 0; i < len(restaurants); i++ {
+		var r = &restaurants[i];
+		if r.Name == "" {
+			Warn("unknown-logo", day, r.Id, fmt.Sprintf("Unknown logo %s on day %d", r.ImageUrl, day));
+		}
+		if r.Description == "" {
+			Warn("missing-description", day, r.Id, fmt.Sprintf("No description for %s on day %d", r.Id, day));
+		}
+		if r.Menu == "" && !r.Closed {
+			Warn("empty-menu", day, r.Id, fmt.Sprintf("Empty menu for %s on day %d", r.Id, day));
+		}
+	}
+}
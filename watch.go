@@ -0,0 +1,85 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Watch mode (-watch): a file dropped in the trigger directory, named
+    after a city (like "falun"), runs the script of that city right away.
+    A simple integration point for systems that can only write files.
+*/
+
+package main
+
+import (
+	"exec"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Seconds between looks in the trigger directory
+//
+const watchInterval = 5;
+
+// Watches the directory for trigger files and runs the command of the
+// city, with {city} replaced by the name of the file. Never returns
+// unless the directory can't be read.
+//
+func Watch(dir string, command string) os.Error {
+	fmt.Printf("Watching %s for triggers\n", dir);
+
+	for {
+		files, err := ioutil.ReadDir(dir);
+		if err != nil {
+			return err;
+		}
+
+		for i := 0; i < len(files); i++ {
+			var name = files[i].Name;
+			if !files[i].IsRegular() || strings.HasPrefix(name, ".") {
+				continue;
+			}
+
+			// The trigger is removed before the run, so a new trigger
+			// dropped during the run is not lost
+			//
+			if err = os.Remove(dir + "/" + name); err != nil {
+				log.Println(err);
+				continue;
+			}
+			if Slug(name) != name {
+				log.Printf("WARNING: Ignoring trigger %s, not a city\n", name);
+				continue;
+			}
+
+			if err = runTrigger(strings.Replace(command, "{city}", name, -1)); err != nil {
+				log.Printf("ERROR: Refresh of %s failed: %s\n", name, err);
+			}
+		}
+		time.Sleep(watchInterval * 1e9);
+	}
+	return nil;
+}
+
+// Runs the command and waits for it to finish
+//
+func runTrigger(command string) os.Error {
+	fmt.Printf("Running %s\n", command);
+
+	cmd, err := exec.Run(command, []string { command }, os.Environ(), "", exec.DevNull, exec.PassThrough, exec.PassThrough);
+	if err != nil {
+		return err;
+	}
+	status, err := cmd.Wait(0);
+	if err != nil {
+		return err;
+	}
+	if status.ExitStatus() != 0 {
+		return os.NewError(fmt.Sprintf("exit status %d", status.ExitStatus()));
+	}
+	return nil;
+}
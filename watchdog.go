@@ -0,0 +1,36 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    A watchdog ending runs that take longer than a hard limit. The timeouts
+    of the downloads don't cover everything, a TLS handshake that never
+    completes once kept a cron run alive for days.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+// Ends the process if it's still running after the given number of
+// seconds. The memory statistics are written to stderr and the panic
+// makes the runtime print the stacks of all goroutines, showing where
+// the run was stuck, before exiting with status 2.
+//
+func StartWatchdog(seconds int) {
+	var start = time.Seconds();
+	go func() {
+		time.Sleep(int64(seconds) * 1e9);
+
+		fmt.Fprintf(os.Stderr, "ERROR: Run not finished after %d seconds (started %s), giving up\n", time.Seconds() - start, time.SecondsToLocalTime(start));
+		var stats = runtime.MemStats;
+		fmt.Fprintf(os.Stderr, "Memory: %d bytes allocated, %d bytes from the system, %d collections\n", stats.Alloc, stats.Sys, stats.NumGC);
+		panic(fmt.Sprintf("watchdog: deadline of %d seconds exceeded", seconds));
+	}();
+}
@@ -0,0 +1,111 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    The weather at lunch time from the forecast of MET Norway (-weather),
+    with a hint about days good for eating outdoors, so the app can
+    suggest restaurants with uteservering on sunny days.
+*/
+
+package main
+
+import (
+	"fmt"
+	"http"
+	"io/ioutil"
+	"json"
+	"os"
+	"strings"
+)
+
+type Weather struct {
+	Temperature float64;		// Degrees Celsius at lunch
+	Symbol string;			// Symbol code of MET Norway, like clearsky_day
+	TerraceFriendly bool;
+}
+
+const forecastUrl = "https://api.met.no/weatherapi/locationforecast/2.0/compact?lat=%.4f&lon=%.4f";
+
+// Lowest temperature for a terrace friendly day and the weather symbols
+// that are fine for eating outdoors
+//
+const terraceTemperature = 15;
+var terraceSymbols = []string { "clearsky", "fair", "partlycloudy" };
+
+// Adds the forecast at lunch to the days of the week. The forecast only
+// reaches about a week ahead, days outside of it get no weather.
+//
+func AddWeather(data *DataStruct, location string, year int64) os.Error {
+	place, err := ParseLocation(location);
+	if err != nil {
+		return err;
+	}
+
+	res, err := Do(&Request{ Method: "GET", Url: fmt.Sprintf(forecastUrl, place.Lat, place.Lon) });
+	if err != nil {
+		return err;
+	}
+	defer res.Body.Close();
+	if res.StatusCode != http.StatusOK {
+		return &StatusError{ forecastUrl, res.Status };
+	}
+	body, err := ioutil.ReadAll(res.Body);
+	if err != nil {
+		return err;
+	}
+
+	var forecast interface{};
+	if err = json.Unmarshal(body, &forecast); err != nil {
+		return err;
+	}
+	var timeseries, _ = lookup(forecast, "properties", "timeseries").([]interface{});
+
+	for day := 0; day < 5; day++ {
+		var t = WeekdayDate(year, data.Week, day);
+		var date = fmt.Sprintf("%04d-%02d-%02dT", t.Year, t.Month, t.Day);
+
+		for i := 0; i < len(timeseries); i++ {
+			var when, _ = lookup(timeseries[i], "time").(string);
+			if !strings.HasPrefix(when, date) || len(when) < len(date) + 2 || when[len(date):len(date) + 2] < "10" {
+				continue;
+			}
+			data.Days[day].Weather = lunchWeather(timeseries[i]);
+			break;
+		}
+	}
+	return nil;
+}
+
+// Returns the weather of an entry in the time series of the forecast
+//
+func lunchWeather(entry interface{}) *Weather {
+	var weather = new(Weather);
+	weather.Temperature, _ = lookup(entry, "data", "instant", "details", "air_temperature").(float64);
+
+	var symbol, found = lookup(entry, "data", "next_1_hours", "summary", "symbol_code").(string);
+	if !found {
+		symbol, _ = lookup(entry, "data", "next_6_hours", "summary", "symbol_code").(string);
+	}
+	weather.Symbol = symbol;
+
+	if weather.Temperature >= terraceTemperature {
+		var kind = strings.Split(symbol, "_", 2)[0];
+		weather.TerraceFriendly = contains(terraceSymbols, kind);
+	}
+	return weather;
+}
+
+// Follows the keys down into decoded JSON objects, nil if any is missing
+//
+func lookup(value interface{}, keys ...string) interface{} {
+	for i := 0; i < len(keys); i++ {
+		var object, ok = value.(map[string]interface{});
+		if !ok {
+			return nil;
+		}
+		value = object[keys[i]];
+	}
+	return value;
+}
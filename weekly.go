@@ -0,0 +1,117 @@
+/*
+  Author: Rickard Andersson
+
+    This file is part of lunchguiden and is distributed under the terms of
+    the GNU General Public License version 3 or later, see lunchguiden.go.
+
+    Detection of restaurants with the same menu all week, typically a
+    weekly buffet, so the app can show "hela veckan" instead of repeating
+    the menu five times.
+*/
+
+package main
+
+// Marks the restaurants with the same menu on all five days. With
+// dedup the menu is only kept on Monday and left out of the other days.
+//
+func MarkWeeklyMenus(data *DataStruct, dedup bool) {
+	if !Enabled("weekly_menus") {
+		return;
+	}
+
+	var menus = make(map[string]string);
+	var count = make(map[string]int);
+
+	for day := 0; day < 5; day++ {
+		var list = append(data.Days[day].Restaurants, data.Days[day].Specials...);
+		for i := 0; i < len(list); i++ {
+			var r = &list[i];
+			if r.Menu == "" {
+				continue;
+			}
+			if menu, found := menus[r.Id]; found && menu != r.Menu {
+				count[r.Id] = -5;
+				continue;
+			}
+			menus[r.Id] = r.Menu;
+			count[r.Id]++;
+		}
+	}
+
+	for day := 0; day < 5; day++ {
+		markWeekly(data.Days[day].Restaurants, count, dedup && day > 0);
+		markWeekly(data.Days[day].Specials, count, dedup && day > 0);
+	}
+}
+
+// Marks the restaurants of a list found with the same menu on five days
+//
+func markWeekly(list []RestData, count map[string]int, dedup bool) {
+	for i := 0; i < len(list); i++ {
+		var r = &list[i];
+		if count[r.Id] != 5 {
+			continue;
+		}
+		r.WeeklyMenu = true;
+		if dedup {
+			r.Menu = "";
+			r.Dishes = nil;
+		}
+	}
+}
+
+// Puts back the menus left out of the days after Monday with dedup and
+// clears the marks, so that the week can be compared and summarized as a
+// whole and marked again with MarkWeeklyMenus
+//
+func UnmarkWeeklyMenus(data *DataStruct) {
+	for day := 4; day >= 0; day-- {
+		unmarkWeekly(data, data.Days[day].Restaurants);
+		unmarkWeekly(data, data.Days[day].Specials);
+	}
+}
+
+// Restores the weekly menus of a list from Monday and clears the marks,
+// Monday itself is done last
+//
+func unmarkWeekly(data *DataStruct, list []RestData) {
+	for i := 0; i < len(list); i++ {
+		var r = &list[i];
+		var monday = mondayEntry(data, r);
+		if monday != r {
+			r.Menu = monday.Menu;
+			r.Dishes = monday.Dishes;
+		}
+		r.WeeklyMenu = false;
+	}
+}
+
+// Returns the dishes of the restaurant, from its entry on Monday if its
+// weekly menu was left out of the day with dedup
+//
+func WeeklyDishes(data *DataStruct, r *RestData) []DishData {
+	return mondayEntry(data, r).Dishes;
+}
+
+// Returns the menu of the restaurant, from its entry on Monday if its
+// weekly menu was left out of the day with dedup
+//
+func WeeklyMenuText(data *DataStruct, r *RestData) string {
+	return mondayEntry(data, r).Menu;
+}
+
+// Returns the entry of the restaurant on Monday if its weekly menu was
+// left out of the day, or the restaurant itself
+//
+func mondayEntry(data *DataStruct, r *RestData) *RestData {
+	if !r.WeeklyMenu || r.Menu != "" || len(r.Dishes) > 0 {
+		return r;
+	}
+	var list = append(data.Days[0].Restaurants, data.Days[0].Specials...);
+	for i := 0; i < len(list); i++ {
+		if list[i].Id == r.Id {
+			return &list[i];
+		}
+	}
+	return r;
+}